@@ -0,0 +1,177 @@
+package main
+
+import (
+	"discocheckbot/api"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// operators are admin_ids of config
+func (this *DiscoCheckBot) isAdmin(userId int64) bool {
+	return slices.Contains(this.admins, userId)
+}
+
+// drafts are checks and imports started but not finished yet
+func (this *DiscoCheckBot) displayAdminStats(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
+	stats, err := this.db.readStats()
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	bot.SendMessage(getAdminStatsMessage(lc, msg.Chat.ID, stats, len(this.checkBuffer), len(this.importBuffer),
+		time.Since(this.startedAt)))
+	return nil
+}
+
+// text after the command is sent as is to all known chats after confirmation
+func (this *DiscoCheckBot) startBroadcast(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
+	delete(this.broadcastBuffer, msg.Sender.ID)
+	text := api.ParseCommandText(*msg)
+	if text == "" {
+		err := newUserError("error.broadcast_empty")
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	chats, err := this.db.listChatIds()
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	this.broadcastBuffer[msg.Sender.ID] = text
+	bot.SendMessage(getBroadcastPreviewMessage(lc, msg.Chat.ID, text, len(chats)))
+	return nil
+}
+
+func (this *DiscoCheckBot) handleBroadcastAction(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
+	oper, err := strconv.Atoi(clbkPar[1])
+	if err != nil {
+		return false, err
+	}
+	text, ok := this.broadcastBuffer[cbq.Sender.ID]
+	delete(this.broadcastBuffer, cbq.Sender.ID)
+	switch oper {
+	case broadcastConfirm:
+		if !ok {
+			err = newUserError("error.nothing_to_broadcast")
+			bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+			return true, err
+		}
+		chats, err := this.db.listChatIds()
+		if err != nil {
+			bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+			return true, err
+		}
+		bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+		bot.EditMessageText(getBroadcastResultEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, chats))
+		// sending takes a while because of throttling, updates are not held meanwhile
+		go sendBroadcast(bot, lc, cbq.Message.Chat.ID, text, chats)
+		return true, nil
+	case broadcastCancel:
+		bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+		bot.EditMessageText(getBroadcastResultEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, nil))
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported broadcast operation %d", oper)
+	}
+}
+
+// chats which removed the bot fail, they are only counted
+func sendBroadcast(bot *api.Bot, lc locale, reportChatId int64, text string, chats []int64) {
+	var sent int
+	for _, chatId := range chats {
+		if _, err := bot.SendMessage(getTextMessage(chatId, text)); err == nil {
+			sent++
+		}
+		time.Sleep(broadcastInterval)
+	}
+	bot.SendMessage(getTextMessage(reportChatId, lc.T("broadcast.done", sent, len(chats))))
+}
+
+// arguments are user id and optional reason, e.g. /ban 12345 spam
+func (this *DiscoCheckBot) banUser(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
+	args := api.ParseCommandArgs(*msg)
+	userId, err := parseIdArg(args, "error.user_id_invalid")
+	if err == nil && this.isAdmin(userId) {
+		err = newUserError("error.ban_admin", userId)
+	}
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	blk := block{UserId: userId, Reason: "admin"}
+	if len(args) > 1 {
+		blk.Reason = strings.Join(args[1:], " ")
+	}
+	if utf8.RuneCountInString(blk.Reason) > maxBlockReasonLength {
+		blk.Reason = string([]rune(blk.Reason)[:maxBlockReasonLength])
+	}
+	if err = this.db.blockUser(&blk, time.Now(), banDuration, banDuration, spamBlockForgottenAfter); err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	if this.spam != nil {
+		this.spam.block(blk)
+	}
+	bot.SendMessage(getTextMessage(msg.Chat.ID, lc.T("admin.banned", userId)))
+	return nil
+}
+
+// lifts a ban as well as a block for flood
+func (this *DiscoCheckBot) unbanUser(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
+	userId, err := parseIdArg(api.ParseCommandArgs(*msg), "error.user_id_invalid")
+	if err == nil {
+		err = this.db.unblockUser(userId)
+	}
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	if this.spam != nil {
+		this.spam.unblock(userId)
+	}
+	bot.SendMessage(getTextMessage(msg.Chat.ID, lc.T("admin.unbanned", userId)))
+	return nil
+}
+
+// any check with its author and attempts, regardless of owner and chat
+func (this *DiscoCheckBot) inspectCheck(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
+	checkId, err := parseIdArg(api.ParseCommandArgs(*msg), "error.check_id_invalid")
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	chk, err := this.readCheck(checkId)
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	owner, err := this.db.readUser(chk.CreatedByUser)
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	bot.SendMessage(getInspectMessage(lc, msg.Chat.ID, chk, owner))
+	return nil
+}
+
+// the first argument is an id, errKey tells what kind of id is expected
+func parseIdArg(args []string, errKey string) (int64, error) {
+	if len(args) == 0 {
+		return 0, newUserError(errKey, "")
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, newUserError(errKey, args[0])
+	}
+	return id, nil
+}
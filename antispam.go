@@ -0,0 +1,169 @@
+package main
+
+import (
+	"discocheckbot/api"
+	"discocheckbot/config"
+	"discocheckbot/logging"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type spamStrikes struct {
+	count int
+	since time.Time
+}
+
+// skips updates over the limits of users and chats, warns the user once,
+// and blocks the user for a while if the flood goes on
+type antiSpam struct {
+	users   *api.RateLimiter
+	chats   *api.RateLimiter
+	mutex   sync.Mutex
+	strikes map[int64]spamStrikes
+	blocked map[int64]time.Time
+	locale  func(sender *api.User) locale
+	db      dbAdapter
+	log     *slog.Logger
+	now     func() time.Time // clock, replaced in tests
+}
+
+// AntiSpam returns middleware which limits updates, blocks are kept in database,
+// so they survive restart, limits are changed with config reload
+func (this *DiscoCheckBot) AntiSpam(cfg *config.Config, log *slog.Logger) (api.Middleware, error) {
+	blocks, err := this.db.listActiveBlocks()
+	if err != nil {
+		return nil, err
+	}
+	spam := &antiSpam{
+		api.NewRateLimiter(cfg.UserUpdatesPerMinute, cfg.UserUpdatesBurst),
+		api.NewRateLimiter(cfg.ChatUpdatesPerMinute, cfg.ChatUpdatesBurst),
+		sync.Mutex{},
+		make(map[int64]spamStrikes),
+		make(map[int64]time.Time),
+		this.locale,
+		this.db,
+		log,
+		time.Now,
+	}
+	for _, blk := range blocks {
+		spam.blocked[blk.UserId] = blk.BlockedUntil
+	}
+	this.spam = spam
+	return spam.middleware, nil
+}
+
+func (this *antiSpam) setLimits(cfg *config.Config) {
+	this.users.SetLimits(cfg.UserUpdatesPerMinute, cfg.UserUpdatesBurst)
+	this.chats.SetLimits(cfg.ChatUpdatesPerMinute, cfg.ChatUpdatesBurst)
+}
+
+func (this *antiSpam) middleware(next api.Handler) api.Handler {
+	return func(bot *api.Bot, update *api.Update) error {
+		sender := update.Sender()
+		if sender == nil {
+			return next(bot, update)
+		}
+		if this.isBlocked(sender.ID) {
+			return api.ErrAccessDenied
+		}
+		userOk, wait := this.users.Allow(sender.ID)
+		chatOk := true
+		// private chat has the same id as the user
+		if chatId := update.ChatID(); chatId != 0 && chatId != sender.ID {
+			var chatWait time.Duration
+			if chatOk, chatWait = this.chats.Allow(chatId); chatWait > wait {
+				wait = chatWait
+			}
+		}
+		if userOk && chatOk {
+			return next(bot, update)
+		}
+		if userOk {
+			this.log.Warn("chat over the limit, update skipped", logging.ChatIDKey, update.ChatID(), logging.UserIDKey, sender.ID)
+		} else {
+			this.strikeUser(bot, update, wait)
+		}
+		return api.ErrRateLimited
+	}
+}
+
+func (this *antiSpam) isBlocked(userId int64) bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	until, ok := this.blocked[userId]
+	if ok && !this.now().Before(until) {
+		delete(this.blocked, userId)
+		return false
+	}
+	return ok
+}
+
+// applies block made outside of the middleware, e.g. ban by operator
+func (this *antiSpam) block(blk block) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.blocked[blk.UserId] = blk.BlockedUntil
+}
+
+func (this *antiSpam) unblock(userId int64) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	delete(this.blocked, userId)
+	delete(this.strikes, userId)
+}
+
+// the first update over the limit gets cooldown notice, too many of them block the user
+func (this *antiSpam) strikeUser(bot *api.Bot, update *api.Update, wait time.Duration) {
+	sender := update.Sender()
+	count := this.countStrike(sender.ID)
+	this.log.Warn("user over the limit", logging.UserIDKey, sender.ID, logging.ChatIDKey, update.ChatID(), "strike", count)
+	lc := this.locale(sender)
+	switch count {
+	case 1:
+		this.notify(bot, update, lc.T("spam.cooldown", int(wait.Seconds())+1))
+	case spamStrikesToBlock:
+		blk := block{UserId: sender.ID, Reason: "flood"}
+		if err := this.db.blockUser(&blk, this.now(), spamBlockDuration, maxSpamBlockDuration, spamBlockForgottenAfter); err != nil {
+			this.log.Error("blocking user failed", "error", err, logging.UserIDKey, sender.ID)
+			return
+		}
+		this.mutex.Lock()
+		this.blocked[blk.UserId] = blk.BlockedUntil
+		delete(this.strikes, blk.UserId)
+		this.mutex.Unlock()
+		this.log.Warn("user blocked", logging.UserIDKey, blk.UserId, "until", blk.BlockedUntil, "times", blk.Times)
+		this.notify(bot, update, lc.T("spam.blocked", lc.dateTime(blk.BlockedUntil)))
+	}
+}
+
+// counts updates of the user over the limit during the window
+func (this *antiSpam) countStrike(userId int64) int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	now := this.now()
+	if len(this.strikes) > maxSpamStrikers {
+		for id, strikes := range this.strikes {
+			if now.Sub(strikes.since) > spamStrikesWindow {
+				delete(this.strikes, id)
+			}
+		}
+	}
+	strikes, ok := this.strikes[userId]
+	if !ok || now.Sub(strikes.since) > spamStrikesWindow {
+		strikes = spamStrikes{0, now}
+	}
+	strikes.count++
+	this.strikes[userId] = strikes
+	return strikes.count
+}
+
+// callback query is answered with alert, message with message, inline query gets nothing
+func (this *antiSpam) notify(bot *api.Bot, update *api.Update, text string) {
+	switch {
+	case update.CallbackQuery != nil:
+		bot.AnswerCallbackQuery(getAlertCbqAnswer(update.CallbackQuery.ID, text))
+	case update.Message != nil:
+		bot.SendMessage(getTextMessage(update.Message.Chat.ID, text))
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"discocheckbot/api"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// keeps blocks in memory, other methods of the database are not used by antiSpam
+type blocksDb struct {
+	dbAdapter
+	blocks map[int64]block
+}
+
+func (this *blocksDb) blockUser(blk *block, now time.Time, duration time.Duration, maxDuration time.Duration, forgetAfter time.Duration) error {
+	blk.extend(this.blocks[blk.UserId], now, duration, maxDuration, forgetAfter)
+	this.blocks[blk.UserId] = *blk
+	return nil
+}
+
+// antiSpam with the clock moved by the test, its limits are not used, as strikes are made directly
+func newTestAntiSpam() (*antiSpam, *time.Time) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	spam := &antiSpam{
+		api.NewRateLimiter(60, 10),
+		api.NewRateLimiter(60, 10),
+		sync.Mutex{},
+		make(map[int64]spamStrikes),
+		make(map[int64]time.Time),
+		func(sender *api.User) locale { return newLocale(sender.LanguageCode) },
+		&blocksDb{blocks: make(map[int64]block)},
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		func() time.Time { return now },
+	}
+	return spam, &now
+}
+
+func TestAntiSpamStrikes(t *testing.T) {
+	spam, now := newTestAntiSpam()
+	for i := 1; i <= 3; i++ {
+		if got := spam.countStrike(1); got != i {
+			t.Errorf("strike %d is counted as %d", i, got)
+		}
+	}
+	if got := spam.countStrike(2); got != 1 {
+		t.Errorf("strike of other user is counted as %d", got)
+	}
+	*now = now.Add(spamStrikesWindow)
+	if got := spam.countStrike(1); got != 4 {
+		t.Errorf("strike at the end of the window is counted as %d, want 4", got)
+	}
+	*now = now.Add(time.Second)
+	if got := spam.countStrike(1); got != 1 {
+		t.Errorf("strike after the window is counted as %d, want 1", got)
+	}
+}
+
+func TestAntiSpamBlocks(t *testing.T) {
+	spam, now := newTestAntiSpam()
+	// inline queries get no notices, so the bot is not needed
+	update := &api.Update{InlineQuery: &api.InlineQuery{Sender: &api.User{ID: 1, LanguageCode: "en"}}}
+	flood := func() {
+		for i := 0; i < spamStrikesToBlock; i++ {
+			spam.strikeUser(nil, update, time.Second)
+		}
+	}
+	// time from the start of the previous block
+	steps := []struct {
+		name    string
+		advance time.Duration
+		want    time.Duration
+	}{
+		{name: "first block", want: spamBlockDuration},
+		{name: "block right after the first one", advance: spamBlockDuration, want: 2 * spamBlockDuration},
+		{name: "third block", advance: 2 * spamBlockDuration, want: 4 * spamBlockDuration},
+		{name: "block after forgotten ones", advance: 4*spamBlockDuration + spamBlockForgottenAfter + time.Second, want: spamBlockDuration},
+	}
+	for _, step := range steps {
+		*now = now.Add(step.advance)
+		if spam.isBlocked(1) {
+			t.Fatalf("%s: user is blocked before the flood", step.name)
+		}
+		flood()
+		if until, ok := spam.blocked[1]; !ok || until.Sub(*now) != step.want {
+			t.Errorf("%s: user is blocked until %v, want for %v", step.name, until, step.want)
+		}
+		if len(spam.strikes) != 0 {
+			t.Errorf("%s: strikes are kept after the block", step.name)
+		}
+		*now = now.Add(step.want - time.Second)
+		if !spam.isBlocked(1) {
+			t.Errorf("%s: user is not blocked until the end of the block", step.name)
+		}
+		*now = now.Add(time.Second)
+		if spam.isBlocked(1) {
+			t.Errorf("%s: user is blocked after the end of the block", step.name)
+		}
+		*now = now.Add(-step.want)
+	}
+}
@@ -3,15 +3,18 @@ package api
 import (
 	"bytes"
 	"discocheckbot/config"
+	"discocheckbot/logging"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
-	"unicode/utf16"
 )
 
 // telegram bot API constants
@@ -19,112 +22,131 @@ const (
 	CommandEntity     string = "bot_command"
 	CrossedEntity     string = "strikethrough"
 	BoldEntity        string = "bold"
+	ItalicEntity      string = "italic"
+	PrivateChat       string = "private"
+	ArticleResult     string = "article"
+	DefaultScope      string = "default"
+	PrivateChatsScope string = "all_private_chats"
+	GroupChatsScope   string = "all_group_chats"
 	apiMethodTemplate string = "https://api.telegram.org/bot<TOKEN>/<METHOD>"
 	apiFileTemplate   string = "https://api.telegram.org/file/bot<TOKEN>/<PATH>"
+	startLinkTemplate string = "https://t.me/<BOT>?start=<PAYLOAD>"
 )
 
 type BotImplementation interface {
 	OnMessage(bot *Bot, msg *Message) error
 	OnCallbackQuery(bot *Bot, cbq *CallbackQuery) error
+	OnInlineQuery(bot *Bot, iq *InlineQuery) error
+	OnChosenInlineResult(bot *Bot, cir *ChosenInlineResult) error
 }
 
 type Bot struct {
 	token           string
+	userName        string
 	updatesOffset   int
 	updatesLimit    int
 	reqUpdatesRetry int //seconds
 	httpTimeout     int //seconds
-	log             *log.Logger
+	log             *slog.Logger
 	implementation  BotImplementation
+	pollState       PollState
+	// guards parameters of polling, which are changed with config reload, and its state
+	mutex sync.Mutex
 }
 
-func NewBot(cfg *config.ConfigReader, log *log.Logger, impl BotImplementation) (*Bot, error) {
-	var token string
-	var httpTimeout, reqUpdatesRetry, updatesLimit float64 //json number interprets as float64!
-	var err error
-	//reading config
-	if err = cfg.GetParameter("bot_token", &token); err != nil {
-		return nil, err
-	}
-	if err = cfg.GetParameter("long_polling_timeout", &httpTimeout); err != nil {
-		return nil, err
-	}
-	if err = cfg.GetParameter("request_updates_retry", &reqUpdatesRetry); err != nil {
-		return nil, err
-	}
-	if err = cfg.GetParameter("updates_limit", &updatesLimit); err != nil {
-		return nil, err
-	}
+// state of polling for health checks
+type PollState struct {
+	// last getUpdates without error
+	LastSuccess time.Time
+	// last progress of the loop: request of updates or update handled, stuck loop has old one
+	LastActivity time.Time
+	// error of the last getUpdates, nil after success
+	LastError error
+	// one request of updates may take that long, and the next one is made after retry delay
+	Timeout    time.Duration
+	RetryDelay time.Duration
+}
+
+func NewBot(cfg *config.Config, log *slog.Logger, impl BotImplementation) (*Bot, error) {
 	//initializing bot
 	bot := Bot{
-		token,
+		cfg.BotToken,
+		"",
 		0,
-		int(updatesLimit),
-		int(reqUpdatesRetry),
-		int(httpTimeout),
+		cfg.UpdatesLimit,
+		cfg.RequestUpdatesRetry,
+		cfg.LongPollingTimeout,
 		log,
 		impl,
+		PollState{LastActivity: time.Now()},
+		sync.Mutex{},
 	}
-	//checking existence of such bot
-	_, err = makeApiRequest(bot.prepareApiUrl("getMe", ""),
+	//checking existence of such bot, its name is needed for links
+	apiResponse, err := makeApiRequest(bot.prepareApiUrl("getMe", ""),
 		"GET",
 		"",
 		nil)
 	if err != nil {
 		return nil, err
 	}
+	var me User
+	if err = json.Unmarshal(apiResponse.Result, &me); err != nil {
+		return nil, err
+	}
+	bot.userName = me.UserName
 	return &bot, nil
 }
 
+// ApplyConfig takes parameters of polling, they are used from the next request of updates
+func (this *Bot) ApplyConfig(prev *config.Config, next *config.Config) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.updatesLimit = next.UpdatesLimit
+	this.reqUpdatesRetry = next.RequestUpdatesRetry
+	this.httpTimeout = next.LongPollingTimeout
+}
+
 func (this *Bot) ListenForUpdates() {
 	for {
+		this.mutex.Lock()
 		requestBody := RequestUpdates{
 			this.updatesOffset,
 			this.updatesLimit,
 			this.httpTimeout,
-			[]string{"message", "callback_query"},
+			[]string{"message", "callback_query", "inline_query", "chosen_inline_result"},
 		}
-		log.Printf("INFO: requesting updates from %d\n", this.updatesOffset)
+		reqUpdatesRetry := this.reqUpdatesRetry
+		this.pollState.LastActivity = time.Now()
+		this.mutex.Unlock()
+		this.log.Debug("requesting updates", logging.UpdateIDKey, this.updatesOffset)
+		begin := time.Now()
 		updates, err := callApiMethod[RequestUpdates, []Update](this.prepareApiUrl("getUpdates", ""), requestBody)
+		getUpdatesSeconds.Since(begin)
+		this.setPollResult(err)
 		if err != nil {
-			log.Printf("ERROR: %v, retrying in %d seconds\n", err, this.reqUpdatesRetry)
-			time.Sleep(time.Second * time.Duration(this.reqUpdatesRetry))
+			this.log.Error("requesting updates failed", "error", err, "retry_in", time.Second*time.Duration(reqUpdatesRetry))
+			time.Sleep(time.Second * time.Duration(reqUpdatesRetry))
 			continue
 		}
 		for _, update := range updates {
 			if update.UpdateID >= this.updatesOffset {
-				if update.CallbackQuery != nil {
-					if err = this.implementation.OnCallbackQuery(this, update.CallbackQuery); err != nil {
-						this.log.Printf("BOT ERROR: %v: callback query %s\nfrom %+v\nchat %d\nmessage %d\nwith %s\n",
-							err,
-							update.CallbackQuery.ID,
-							update.CallbackQuery.Sender,
-							update.CallbackQuery.Message.Chat.ID,
-							update.CallbackQuery.Message.MessageID,
-							update.CallbackQuery.Data)
-					} else {
-						this.log.Printf("BOT INFO: callback query %s\nfrom %+v\nchat %d\nmessage %d\nwith %s\n",
-							update.CallbackQuery.ID,
-							update.CallbackQuery.Sender,
-							update.CallbackQuery.Message.Chat.ID,
-							update.CallbackQuery.Message.MessageID,
-							update.CallbackQuery.Data)
-					}
-				} else if update.Message != nil {
-					if err = this.implementation.OnMessage(this, update.Message); err != nil {
-						this.log.Printf("BOT ERROR: %v: message %d\nfrom %+v\nchat %d\nwith %s\n",
-							err,
-							update.Message.MessageID,
-							update.Message.Sender,
-							update.Message.Chat.ID,
-							update.Message.Text)
-					} else {
-						this.log.Printf("BOT INFO: message %d\nfrom %+v\nchat %d\nwith %s\n",
-							update.Message.MessageID,
-							update.Message.Sender,
-							update.Message.Chat.ID,
-							update.Message.Text)
+				kind := updateKind(&update)
+				updatesTotal.Inc(kind)
+				if update.Message != nil && this.addressedToOtherBot(*update.Message) {
+					this.log.Debug("message skipped, addressed to another bot",
+						logging.UpdateIDKey, update.UpdateID,
+						logging.ChatIDKey, update.Message.Chat.ID,
+						logging.MessageIDKey, update.Message.MessageID)
+				} else {
+					// errors are logged by middlewares, e.g. Logging
+					begin = time.Now()
+					if err = dispatch(this.implementation, this, &update); err != nil {
+						handlerErrorsTotal.Inc(kind, errorKind(err))
 					}
+					handlerSeconds.Since(begin, kind)
+					this.mutex.Lock()
+					this.pollState.LastActivity = time.Now()
+					this.mutex.Unlock()
 				}
 				this.updatesOffset = update.UpdateID + 1
 			}
@@ -132,6 +154,26 @@ func (this *Bot) ListenForUpdates() {
 	}
 }
 
+func (this *Bot) setPollResult(err error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.pollState.LastActivity = time.Now()
+	this.pollState.LastError = err
+	if err == nil {
+		this.pollState.LastSuccess = this.pollState.LastActivity
+	}
+}
+
+// PollState tells how polling of updates goes, safe to call from other goroutines
+func (this *Bot) PollState() PollState {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	state := this.pollState
+	state.Timeout = time.Second * time.Duration(this.httpTimeout)
+	state.RetryDelay = time.Second * time.Duration(this.reqUpdatesRetry)
+	return state
+}
+
 func makeApiRequest(url string, httpMethod string, contentType string, body []byte) (*ApiResponse, error) {
 	request, err := http.NewRequest(httpMethod, url, bytes.NewReader(body))
 	if err != nil {
@@ -140,11 +182,13 @@ func makeApiRequest(url string, httpMethod string, contentType string, body []by
 	request.Header.Set("Content-Type", contentType)
 	response, err := http.DefaultClient.Do(request)
 	if err != nil {
-		return nil, err
+		apiCallsTotal.Inc(apiMethodOf(url), "error")
+		return nil, hideUrl(err)
 	}
 	defer response.Body.Close()
+	apiCallsTotal.Inc(apiMethodOf(url), strconv.Itoa(response.StatusCode))
 	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request for %s failed with http status code %d", url, response.StatusCode)
+		return nil, fmt.Errorf("request for %s failed with http status code %d", apiMethodOf(url), response.StatusCode)
 	}
 	responseBody, err := io.ReadAll(response.Body)
 	if err != nil {
@@ -156,11 +200,38 @@ func makeApiRequest(url string, httpMethod string, contentType string, body []by
 		return nil, err
 	}
 	if !apiResponse.Ok {
-		return nil, fmt.Errorf("request for %s failed with telegram error code %d", url, apiResponse.ErrorCode)
+		return nil, fmt.Errorf("request for %s failed with telegram error code %d", apiMethodOf(url), apiResponse.ErrorCode)
 	}
 	return &apiResponse, nil
 }
 
+// urls of requests contain the token, so errors keep only the method
+func hideUrl(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s %s: %w", urlErr.Op, apiMethodOf(urlErr.URL), urlErr.Err)
+	}
+	return err
+}
+
+// e.g. "sendMessage" of api method url, "file" of file url
+func apiMethodOf(url string) string {
+	if strings.Contains(url, "/file/") {
+		return "file"
+	}
+	return url[strings.LastIndex(url, "/")+1:]
+}
+
+func (this *Bot) UserName() string {
+	return this.userName
+}
+
+// link opening private chat with the bot, which sends /start with the payload
+func (this *Bot) StartLink(payload string) string {
+	link := strings.Replace(startLinkTemplate, "<BOT>", this.userName, 1)
+	return strings.Replace(link, "<PAYLOAD>", payload, 1)
+}
+
 func (this *Bot) prepareApiUrl(apiMethod string, filePath string) string {
 	var url string
 	if apiMethod != "" {
@@ -175,13 +246,38 @@ func (this *Bot) prepareApiUrl(apiMethod string, filePath string) string {
 func (this *Bot) SendMessage(msg SendMessage) (*Message, error) {
 	retMsg, err := callApiMethod[SendMessage, *Message](this.prepareApiUrl("sendMessage", ""), msg)
 	if err != nil {
-		this.log.Printf("ERROR: %v: send message chat %d\n",
-			err,
-			msg.ChatID)
+		this.log.Error("send message failed", "error", err,
+			logging.ChatIDKey, msg.ChatID)
+	} else {
+		this.log.Debug("send message",
+			logging.ChatIDKey, retMsg.Chat.ID,
+			logging.MessageIDKey, retMsg.MessageID)
+	}
+	return retMsg, err
+}
+
+func (this *Bot) SendPhoto(photo SendPhoto) (*Message, error) {
+	retMsg, err := callApiMethod[SendPhoto, *Message](this.prepareApiUrl("sendPhoto", ""), photo)
+	if err != nil {
+		this.log.Error("send photo failed", "error", err,
+			logging.ChatIDKey, photo.ChatID)
+	} else {
+		this.log.Debug("send photo",
+			logging.ChatIDKey, retMsg.Chat.ID,
+			logging.MessageIDKey, retMsg.MessageID)
+	}
+	return retMsg, err
+}
+
+func (this *Bot) SendReplyKeyboard(msg SendReplyKeyboard) (*Message, error) {
+	retMsg, err := callApiMethod[SendReplyKeyboard, *Message](this.prepareApiUrl("sendMessage", ""), msg)
+	if err != nil {
+		this.log.Error("send reply keyboard failed", "error", err,
+			logging.ChatIDKey, msg.ChatID)
 	} else {
-		this.log.Printf("INFO: send message %d\nchat %d\n",
-			retMsg.MessageID,
-			retMsg.Chat.ID)
+		this.log.Debug("send reply keyboard",
+			logging.ChatIDKey, retMsg.Chat.ID,
+			logging.MessageIDKey, retMsg.MessageID)
 	}
 	return retMsg, err
 }
@@ -189,15 +285,13 @@ func (this *Bot) SendMessage(msg SendMessage) (*Message, error) {
 func (this *Bot) EditMessageText(msg EditMessageText) (*Message, error) {
 	retMsg, err := callApiMethod[EditMessageText, *Message](this.prepareApiUrl("editMessageText", ""), msg)
 	if err != nil {
-		this.log.Printf("ERROR: %v: edit message %d\nchat %d\n",
-			err,
-			msg.MessageID,
-			msg.ChatID)
+		this.log.Error("edit message failed", "error", err,
+			logging.ChatIDKey, msg.ChatID,
+			logging.MessageIDKey, msg.MessageID)
 	} else {
-		this.log.Printf("INFO: edit message %d\nfrom %+v\nchat %d\n",
-			retMsg.MessageID,
-			retMsg.Sender,
-			retMsg.Chat.ID)
+		this.log.Debug("edit message",
+			logging.ChatIDKey, retMsg.Chat.ID,
+			logging.MessageIDKey, retMsg.MessageID)
 	}
 	return retMsg, err
 }
@@ -205,22 +299,132 @@ func (this *Bot) EditMessageText(msg EditMessageText) (*Message, error) {
 func (this *Bot) AnswerCallbackQuery(answer AnswerCallbackQuery) (*bool, error) {
 	retOk, err := callApiMethod[AnswerCallbackQuery, *bool](this.prepareApiUrl("answerCallbackQuery", ""), answer)
 	if err != nil {
-		this.log.Printf("ERROR %v: answer callback query %s\n",
-			err,
-			answer.CallbackQueryId)
+		this.log.Error("answer callback query failed", "error", err,
+			"callback_query_id", answer.CallbackQueryId)
 	} else {
-		this.log.Printf("INFO: answer callback query %s\n",
-			answer.CallbackQueryId)
+		this.log.Debug("answer callback query",
+			"callback_query_id", answer.CallbackQueryId)
 	}
 	return retOk, err
 }
 
+func (this *Bot) AnswerInlineQuery(answer AnswerInlineQuery) (*bool, error) {
+	retOk, err := callApiMethod[AnswerInlineQuery, *bool](this.prepareApiUrl("answerInlineQuery", ""), answer)
+	if err != nil {
+		this.log.Error("answer inline query failed", "error", err,
+			"inline_query_id", answer.InlineQueryID)
+	} else {
+		this.log.Debug("answer inline query",
+			"inline_query_id", answer.InlineQueryID,
+			"results", len(answer.Results))
+	}
+	return retOk, err
+}
+
+func (this *Bot) SetMyCommands(cmds SetMyCommands) (*bool, error) {
+	retOk, err := callApiMethod[SetMyCommands, *bool](this.prepareApiUrl("setMyCommands", ""), cmds)
+	if err != nil {
+		this.log.Error("set my commands failed", "error", err,
+			"scope", scopeType(cmds.Scope),
+			"language", cmds.LanguageCode)
+	} else {
+		this.log.Info("set my commands",
+			"scope", scopeType(cmds.Scope),
+			"language", cmds.LanguageCode,
+			"commands", len(cmds.Commands))
+	}
+	return retOk, err
+}
+
+func (this *Bot) GetMyCommands(cmds GetMyCommands) ([]BotCommand, error) {
+	retCmds, err := callApiMethod[GetMyCommands, []BotCommand](this.prepareApiUrl("getMyCommands", ""), cmds)
+	if err != nil {
+		this.log.Error("get my commands failed", "error", err,
+			"scope", scopeType(cmds.Scope),
+			"language", cmds.LanguageCode)
+	}
+	return retCmds, err
+}
+
+func (this *Bot) DeleteMyCommands(cmds DeleteMyCommands) (*bool, error) {
+	retOk, err := callApiMethod[DeleteMyCommands, *bool](this.prepareApiUrl("deleteMyCommands", ""), cmds)
+	if err != nil {
+		this.log.Error("delete my commands failed", "error", err,
+			"scope", scopeType(cmds.Scope),
+			"language", cmds.LanguageCode)
+	} else {
+		this.log.Info("delete my commands",
+			"scope", scopeType(cmds.Scope),
+			"language", cmds.LanguageCode)
+	}
+	return retOk, err
+}
+
+func (this *Bot) GetFile(fileId string) (*File, error) {
+	retFile, err := callApiMethod[GetFile, *File](this.prepareApiUrl("getFile", ""), GetFile{fileId})
+	if err != nil {
+		this.log.Error("get file failed", "error", err,
+			"file_id", fileId)
+	} else {
+		this.log.Debug("get file",
+			"file_id", retFile.FileID,
+			"size", retFile.FileSize)
+	}
+	return retFile, err
+}
+
+// downloads file content, file must be obtained with GetFile first
+func (this *Bot) DownloadFile(file *File, maxSize int64) ([]byte, error) {
+	if file.FilePath == "" {
+		return nil, fmt.Errorf("file %s has no path to download", file.FileID)
+	}
+	if maxSize > 0 && file.FileSize > maxSize {
+		return nil, fmt.Errorf("file %s is too large: %d bytes, maximum %d", file.FileID, file.FileSize, maxSize)
+	}
+	response, err := http.Get(this.prepareApiUrl("", file.FilePath))
+	if err != nil {
+		apiCallsTotal.Inc("file", "error")
+		err = hideUrl(err)
+		this.log.Error("download file failed", "error", err, "file_id", file.FileID)
+		return nil, err
+	}
+	defer response.Body.Close()
+	apiCallsTotal.Inc("file", strconv.Itoa(response.StatusCode))
+	if response.StatusCode != http.StatusOK {
+		err = fmt.Errorf("download of file %s failed with http status code %d", file.FileID, response.StatusCode)
+		this.log.Error("download file failed", "error", err, "file_id", file.FileID)
+		return nil, err
+	}
+	var reader io.Reader = response.Body
+	if maxSize > 0 {
+		reader = io.LimitReader(response.Body, maxSize+1)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && int64(len(content)) > maxSize {
+		return nil, fmt.Errorf("file %s is too large, maximum %d bytes", file.FileID, maxSize)
+	}
+	this.log.Debug("download file", "file_id", file.FileID, "size", len(content))
+	return content, nil
+}
+
+// missing scope is the default one
+func scopeType(scope *BotCommandScope) string {
+	if scope == nil {
+		return DefaultScope
+	}
+	return scope.Type
+}
+
 type allowedIn interface {
-	EditMessageText | SendMessage | RequestUpdates | AnswerCallbackQuery
+	EditMessageText | SendMessage | SendPhoto | SendReplyKeyboard | RequestUpdates | AnswerCallbackQuery | AnswerInlineQuery | GetFile |
+		SetMyCommands | GetMyCommands | DeleteMyCommands
 }
 
 type allowedOut interface {
-	*Message | []Update | *bool
+	*Message | []Update | *bool | *File | []BotCommand
 }
 
 func callApiMethod[I allowedIn, O allowedOut](url string, requestBody I) (O, error) {
@@ -242,19 +446,3 @@ func callApiMethod[I allowedIn, O allowedOut](url string, requestBody I) (O, err
 	}
 	return responseBody, nil
 }
-
-func ParseCommand(message Message) (string, error) {
-	var command string
-	for _, entity := range message.Entities {
-		if entity.Type == CommandEntity {
-			msgText16 := utf16.Encode([]rune(message.Text))
-			substrTo := entity.Offset + entity.Length
-			if substrTo > len(msgText16) {
-				return "", errors.New("bad command: text too short")
-			}
-			command16 := msgText16[entity.Offset+1 : substrTo] // omit slash
-			command = string(utf16.Decode(command16))
-		}
-	}
-	return command, nil
-}
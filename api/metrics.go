@@ -0,0 +1,32 @@
+package api
+
+import (
+	"discocheckbot/metrics"
+	"errors"
+)
+
+var (
+	updatesTotal = metrics.Default.NewCounterVec("discocheckbot_updates_total",
+		"Updates received from telegram.", "type")
+	handlerErrorsTotal = metrics.Default.NewCounterVec("discocheckbot_handler_errors_total",
+		"Updates not handled, by kind of error.", "type", "kind")
+	handlerSeconds = metrics.Default.NewHistogramVec("discocheckbot_handler_duration_seconds",
+		"Time of handling an update, with middlewares.", metrics.LatencyBuckets, "type")
+	apiCallsTotal = metrics.Default.NewCounterVec("discocheckbot_telegram_api_calls_total",
+		"Requests to telegram bot api, status is http status code or \"error\" if there is no response.", "method", "status")
+	getUpdatesSeconds = metrics.Default.NewHistogramVec("discocheckbot_get_updates_duration_seconds",
+		"Round trip of getUpdates, including long polling.", metrics.LatencyBuckets)
+)
+
+// kind of error for metrics, e.g. to alert on panics but not on skipped floods
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrAccessDenied):
+		return "access_denied"
+	case errors.Is(err, ErrPanic):
+		return "panic"
+	}
+	return "failed"
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"discocheckbot/logging"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+// errors of built-in middlewares, the update is not passed further
+var (
+	ErrRateLimited  = errors.New("too many updates from the user")
+	ErrAccessDenied = errors.New("access is denied")
+	ErrPanic        = errors.New("handler panicked")
+)
+
+// handles one update of any kind
+type Handler func(bot *Bot, update *Update) error
+
+// wraps handler, e.g. to skip the update or to do something around it
+type Middleware func(next Handler) Handler
+
+type chain struct {
+	handler Handler
+}
+
+// Chain wraps the implementation with middlewares, the first one is the outermost,
+// e.g. Chain(impl, Logging(log), Recover(log)) logs panics recovered in impl
+func Chain(impl BotImplementation, middlewares ...Middleware) BotImplementation {
+	handler := func(bot *Bot, update *Update) error {
+		return dispatch(impl, bot, update)
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return chain{handler}
+}
+
+func (this chain) OnMessage(bot *Bot, msg *Message) error {
+	return this.handler(bot, &Update{Message: msg})
+}
+
+func (this chain) OnCallbackQuery(bot *Bot, cbq *CallbackQuery) error {
+	return this.handler(bot, &Update{CallbackQuery: cbq})
+}
+
+func (this chain) OnInlineQuery(bot *Bot, iq *InlineQuery) error {
+	return this.handler(bot, &Update{InlineQuery: iq})
+}
+
+func (this chain) OnChosenInlineResult(bot *Bot, cir *ChosenInlineResult) error {
+	return this.handler(bot, &Update{ChosenInlineResult: cir})
+}
+
+// passes update to the method of implementation for its kind,
+// chain gets the whole update, so middlewares see its id
+func dispatch(impl BotImplementation, bot *Bot, update *Update) error {
+	if chain, ok := impl.(chain); ok {
+		return chain.handler(bot, update)
+	}
+	switch {
+	case update.CallbackQuery != nil:
+		return impl.OnCallbackQuery(bot, update.CallbackQuery)
+	case update.InlineQuery != nil:
+		return impl.OnInlineQuery(bot, update.InlineQuery)
+	case update.ChosenInlineResult != nil:
+		return impl.OnChosenInlineResult(bot, update.ChosenInlineResult)
+	case update.Message != nil:
+		return impl.OnMessage(bot, update.Message)
+	}
+	return nil
+}
+
+// Recover turns panic of the handler into error, so one bad update does not stop the bot
+func Recover(log *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(bot *Bot, update *Update) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Error("panic in handler", append(updateAttrs(update), "panic", r, "stack", string(debug.Stack()))...)
+					err = fmt.Errorf("%w: %v", ErrPanic, r)
+				}
+			}()
+			return next(bot, update)
+		}
+	}
+}
+
+// Logging reports every update with its result and time spent on it
+func Logging(log *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(bot *Bot, update *Update) error {
+			begin := time.Now()
+			err := next(bot, update)
+			attrs := append(updateAttrs(update), "duration", time.Since(begin))
+			switch {
+			case errors.Is(err, ErrRateLimited) || errors.Is(err, ErrAccessDenied):
+				log.Warn("update skipped", append(attrs, "error", err)...)
+			case err != nil:
+				log.Error("update failed", append(attrs, "error", err)...)
+			default:
+				log.Info("update handled", attrs...)
+			}
+			return err
+		}
+	}
+}
+
+// AccessList skips updates from denied users and chats, if allowed ones are given,
+// updates from anyone else are skipped too
+func AccessList(allowed []int64, denied []int64) Middleware {
+	return func(next Handler) Handler {
+		return func(bot *Bot, update *Update) error {
+			var userId int64
+			if sender := update.Sender(); sender != nil {
+				userId = sender.ID
+			}
+			chatId := update.ChatID()
+			if slices.Contains(denied, userId) || slices.Contains(denied, chatId) {
+				return ErrAccessDenied
+			}
+			if len(allowed) > 0 && !slices.Contains(allowed, userId) && !slices.Contains(allowed, chatId) {
+				return ErrAccessDenied
+			}
+			return next(bot, update)
+		}
+	}
+}
+
+// key-value pairs of the update for log records, user content is under logging.TextKey
+func updateAttrs(update *Update) []any {
+	attrs := []any{logging.UpdateIDKey, update.UpdateID}
+	if sender := update.Sender(); sender != nil {
+		attrs = append(attrs, logging.UserIDKey, sender.ID)
+	}
+	if chatId := update.ChatID(); chatId != 0 {
+		attrs = append(attrs, logging.ChatIDKey, chatId)
+	}
+	attrs = append(attrs, "kind", updateKind(update))
+	switch {
+	case update.CallbackQuery != nil:
+		attrs = append(attrs, "data", update.CallbackQuery.Data)
+		if update.CallbackQuery.Message != nil {
+			attrs = append(attrs, logging.MessageIDKey, update.CallbackQuery.Message.MessageID)
+		}
+	case update.InlineQuery != nil:
+		attrs = append(attrs, logging.TextKey, update.InlineQuery.Query)
+	case update.ChosenInlineResult != nil:
+		attrs = append(attrs, "result_id", update.ChosenInlineResult.ResultID,
+			logging.TextKey, update.ChosenInlineResult.Query)
+	case update.Message != nil:
+		attrs = append(attrs, logging.MessageIDKey, update.Message.MessageID,
+			logging.TextKey, update.Message.Text)
+	}
+	return attrs
+}
+
+// e.g. "message", for log records and metrics
+func updateKind(update *Update) string {
+	switch {
+	case update.CallbackQuery != nil:
+		return "callback_query"
+	case update.InlineQuery != nil:
+		return "inline_query"
+	case update.ChosenInlineResult != nil:
+		return "chosen_inline_result"
+	case update.Message != nil:
+		return "message"
+	}
+	return "unknown"
+}
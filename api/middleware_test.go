@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// records updates passed to it, panics on messages with text "panic"
+type recordingImplementation struct {
+	updates []string
+}
+
+func (this *recordingImplementation) OnMessage(bot *Bot, msg *Message) error {
+	if msg.Text == "panic" {
+		panic("broken message")
+	}
+	this.updates = append(this.updates, "message")
+	return nil
+}
+
+func (this *recordingImplementation) OnCallbackQuery(bot *Bot, cbq *CallbackQuery) error {
+	this.updates = append(this.updates, "callback_query")
+	return nil
+}
+
+func (this *recordingImplementation) OnInlineQuery(bot *Bot, iq *InlineQuery) error {
+	this.updates = append(this.updates, "inline_query")
+	return nil
+}
+
+func (this *recordingImplementation) OnChosenInlineResult(bot *Bot, cir *ChosenInlineResult) error {
+	this.updates = append(this.updates, "chosen_inline_result")
+	return nil
+}
+
+func messageFrom(userId int64, chatId int64, text string) *Message {
+	return &Message{Sender: &User{ID: userId}, Chat: &Chat{ID: chatId}, Text: text}
+}
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	named := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(bot *Bot, update *Update) error {
+				calls = append(calls, name+" before")
+				err := next(bot, update)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+	impl := &recordingImplementation{}
+	// chain of chain is flattened, the whole update reaches inner middlewares
+	bot := Chain(Chain(impl, named("inner")), named("first"), named("second"))
+	if err := bot.OnMessage(nil, messageFrom(1, 1, "hi")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := bot.OnCallbackQuery(nil, &CallbackQuery{Sender: &User{ID: 1}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"first before", "second before", "inner before", "inner after", "second after", "first after",
+		"first before", "second before", "inner before", "inner after", "second after", "first after",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if wantUpdates := []string{"message", "callback_query"}; !reflect.DeepEqual(impl.updates, wantUpdates) {
+		t.Errorf("updates = %q, want %q", impl.updates, wantUpdates)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	var out bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&out, nil))
+	impl := &recordingImplementation{}
+	bot := Chain(impl, Recover(log))
+	err := bot.OnMessage(nil, messageFrom(1, 2, "panic"))
+	if !errors.Is(err, ErrPanic) || !strings.Contains(err.Error(), "broken message") {
+		t.Fatalf("error = %v, want ErrPanic", err)
+	}
+	if !strings.Contains(out.String(), "panic in handler") {
+		t.Errorf("panic is not logged: %s", out.String())
+	}
+	// the bot keeps handling updates after the panic
+	if err := bot.OnMessage(nil, messageFrom(1, 2, "hi")); err != nil || len(impl.updates) != 1 {
+		t.Errorf("update after panic: error %v, updates %q", err, impl.updates)
+	}
+}
+
+func TestAccessList(t *testing.T) {
+	const user, otherUser, group, otherGroup int64 = 1, 2, -100, -200
+	tests := []struct {
+		name    string
+		allowed []int64
+		denied  []int64
+		update  *Update
+		wantErr bool
+	}{
+		{name: "no lists", update: &Update{Message: messageFrom(user, group, "")}},
+		{name: "allowed user", allowed: []int64{user}, update: &Update{Message: messageFrom(user, otherGroup, "")}},
+		{name: "allowed chat", allowed: []int64{group}, update: &Update{Message: messageFrom(otherUser, group, "")}},
+		{name: "not allowed", allowed: []int64{user, group}, update: &Update{Message: messageFrom(otherUser, otherGroup, "")}, wantErr: true},
+		{name: "denied user", denied: []int64{user}, update: &Update{Message: messageFrom(user, group, "")}, wantErr: true},
+		{name: "denied chat", denied: []int64{group}, update: &Update{Message: messageFrom(user, group, "")}, wantErr: true},
+		{name: "other user of denied list", denied: []int64{user}, update: &Update{Message: messageFrom(otherUser, group, "")}},
+		{
+			name:    "denied user in allowed chat",
+			allowed: []int64{group},
+			denied:  []int64{user},
+			update:  &Update{Message: messageFrom(user, group, "")},
+			wantErr: true,
+		},
+		{
+			name:    "allowed user in denied chat",
+			allowed: []int64{user},
+			denied:  []int64{group},
+			update:  &Update{Message: messageFrom(user, group, "")},
+			wantErr: true,
+		},
+		{
+			name:    "inline query of allowed user",
+			allowed: []int64{user},
+			update:  &Update{InlineQuery: &InlineQuery{Sender: &User{ID: user}}},
+		},
+		{
+			name:    "inline query of user of allowed chat",
+			allowed: []int64{group},
+			update:  &Update{InlineQuery: &InlineQuery{Sender: &User{ID: user}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			impl := &recordingImplementation{}
+			handler := AccessList(tt.allowed, tt.denied)(func(bot *Bot, update *Update) error {
+				return dispatch(impl, bot, update)
+			})
+			err := handler(nil, tt.update)
+			if tt.wantErr {
+				if !errors.Is(err, ErrAccessDenied) || len(impl.updates) > 0 {
+					t.Errorf("error = %v, updates %q, want ErrAccessDenied", err, impl.updates)
+				}
+				return
+			}
+			if err != nil || len(impl.updates) != 1 {
+				t.Errorf("error = %v, updates %q, want the update handled", err, impl.updates)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// buckets which are full are forgotten, when there are more keys than this
+const maxRateLimitedKeys int = 10000
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// token buckets by key, e.g. by user id, each one holds up to burst tokens
+// and gets perMinute tokens a minute, safe for concurrent use
+type RateLimiter struct {
+	perMinute float64
+	burst     float64
+	mutex     sync.Mutex
+	buckets   map[int64]tokenBucket
+	now       func() time.Time // clock, replaced in tests
+}
+
+func NewRateLimiter(perMinute float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		perMinute: perMinute,
+		burst:     float64(burst),
+		buckets:   make(map[int64]tokenBucket),
+		now:       time.Now,
+	}
+}
+
+// changed limits apply to tokens added from now on
+func (this *RateLimiter) SetLimits(perMinute float64, burst int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	now := this.now()
+	for key, bucket := range this.buckets {
+		this.buckets[key] = tokenBucket{math.Min(float64(burst), this.refill(bucket, now)), now}
+	}
+	this.perMinute = perMinute
+	this.burst = float64(burst)
+}
+
+// Allow takes a token from the bucket of the key, if there is none, returns time to wait for it
+func (this *RateLimiter) Allow(key int64) (bool, time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	now := this.now()
+	if len(this.buckets) > maxRateLimitedKeys {
+		for k, bucket := range this.buckets {
+			if this.refill(bucket, now) >= this.burst {
+				delete(this.buckets, k)
+			}
+		}
+	}
+	bucket, ok := this.buckets[key]
+	if !ok {
+		bucket.tokens = this.burst
+	} else {
+		bucket.tokens = this.refill(bucket, now)
+	}
+	bucket.updated = now
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	this.buckets[key] = bucket
+	if allowed {
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) / this.perMinute * float64(time.Minute))
+}
+
+func (this *RateLimiter) refill(bucket tokenBucket, now time.Time) float64 {
+	return math.Min(this.burst, bucket.tokens+now.Sub(bucket.updated).Minutes()*this.perMinute)
+}
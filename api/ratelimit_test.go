@@ -0,0 +1,95 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+// limiter with the clock moved by the test
+func newTestRateLimiter(perMinute float64, burst int) (*RateLimiter, *time.Time) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(perMinute, burst)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter, now := newTestRateLimiter(6, 2)
+	steps := []struct {
+		name     string
+		advance  time.Duration
+		key      int64
+		wantOk   bool
+		wantWait time.Duration
+	}{
+		{name: "burst", key: 1, wantOk: true},
+		{name: "rest of burst", key: 1, wantOk: true},
+		{name: "empty bucket", key: 1, wantWait: 10 * time.Second},
+		{name: "other key", key: 2, wantOk: true},
+		{name: "partly refilled", advance: 5 * time.Second, key: 1, wantWait: 5 * time.Second},
+		{name: "refilled token", advance: 5 * time.Second, key: 1, wantOk: true},
+		{name: "refill is not over burst", advance: time.Hour, key: 1, wantOk: true},
+		{name: "burst after long pause", key: 1, wantOk: true},
+		{name: "empty bucket after long pause", key: 1, wantWait: 10 * time.Second},
+	}
+	for _, step := range steps {
+		*now = now.Add(step.advance)
+		ok, wait := limiter.Allow(step.key)
+		if ok != step.wantOk || wait.Round(time.Millisecond) != step.wantWait {
+			t.Errorf("%s: Allow = %v, %v, want %v, %v", step.name, ok, wait, step.wantOk, step.wantWait)
+		}
+	}
+}
+
+func TestRateLimiterSetLimits(t *testing.T) {
+	limiter, now := newTestRateLimiter(60, 5)
+	limiter.Allow(1)
+	// 4 tokens left are cut to the new burst
+	limiter.SetLimits(6, 2)
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow(1); !ok {
+			t.Fatalf("update %d is not allowed", i+1)
+		}
+	}
+	if ok, wait := limiter.Allow(1); ok || wait != 10*time.Second {
+		t.Errorf("Allow over the new burst = %v, %v, want false, 10s", ok, wait)
+	}
+	// raised burst does not add tokens, they come at the new rate
+	limiter.SetLimits(60, 10)
+	*now = now.Add(2 * time.Second)
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow(1); !ok {
+			t.Fatalf("refilled update %d is not allowed", i+1)
+		}
+	}
+	if ok, _ := limiter.Allow(1); ok {
+		t.Error("raised burst added tokens")
+	}
+	// new keys get the whole new burst
+	for i := 0; i < 10; i++ {
+		if ok, _ := limiter.Allow(2); !ok {
+			t.Fatalf("update %d of new key is not allowed", i+1)
+		}
+	}
+}
+
+func TestRateLimiterEviction(t *testing.T) {
+	limiter, now := newTestRateLimiter(1, 2)
+	for key := 0; key <= maxRateLimitedKeys; key++ {
+		limiter.Allow(int64(key))
+	}
+	// the bucket of the busy key is not full yet, when the others are
+	*now = now.Add(30 * time.Second)
+	limiter.Allow(0)
+	*now = now.Add(30 * time.Second)
+	if len(limiter.buckets) != maxRateLimitedKeys+1 {
+		t.Fatalf("%d buckets are kept before eviction, want %d", len(limiter.buckets), maxRateLimitedKeys+1)
+	}
+	limiter.Allow(-1)
+	if len(limiter.buckets) != 2 {
+		t.Errorf("%d buckets are kept after eviction, want busy and new ones", len(limiter.buckets))
+	}
+	if _, ok := limiter.buckets[0]; !ok {
+		t.Error("bucket of the busy key is forgotten")
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf16"
+)
+
+// command is recognized only at the beginning of the message, as clients send it,
+// returns it with the mention of the bot and text after it,
+// e.g. "top@discocheckbot" and " party" for "/top@discocheckbot party"
+func splitCommand(message Message) (string, string, error) {
+	for _, entity := range message.Entities {
+		if entity.Type == CommandEntity && entity.Offset == 0 {
+			msgText16 := utf16.Encode([]rune(message.Text))
+			if entity.Length > len(msgText16) {
+				return "", "", errors.New("bad command: text too short")
+			}
+			command16 := msgText16[1:entity.Length] // omit slash
+			return string(utf16.Decode(command16)), string(utf16.Decode(msgText16[entity.Length:])), nil
+		}
+	}
+	return "", "", nil
+}
+
+// returns command without mention of the bot, e.g. "top" for "/top@discocheckbot",
+// messages with commands for other bots are not passed to BotImplementation at all
+func ParseCommand(message Message) (string, error) {
+	command, _, err := splitCommand(message)
+	name, _, _ := strings.Cut(command, "@")
+	return name, err
+}
+
+// returns words following the command, e.g. ["party"] for "/white party"
+func ParseCommandArgs(message Message) []string {
+	_, args, err := splitCommand(message)
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(args)
+}
+
+// returns text following the command as is, with line breaks, e.g. for announcements
+func ParseCommandText(message Message) string {
+	_, text, err := splitCommand(message)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(text)
+}
+
+// in group chats commands may be addressed to any bot with its username, e.g. /start@otherbot
+func (this *Bot) addressedToOtherBot(message Message) bool {
+	command, _, err := splitCommand(message)
+	_, mention, found := strings.Cut(command, "@")
+	return err == nil && found && !strings.EqualFold(mention, this.userName)
+}
+
+// command carries mention of the bot, e.g. "/top@discocheckbot",
+// mentions of other bots do not reach BotImplementation, so the mention is ours
+func IsCommandAddressed(message Message) bool {
+	command, _, err := splitCommand(message)
+	return err == nil && strings.Contains(command, "@")
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommandRouting(t *testing.T) {
+	bot := &Bot{userName: "ThisBot"}
+	command := func(offset int, length int) []MessageEntity {
+		return []MessageEntity{{Type: CommandEntity, Offset: offset, Length: length}}
+	}
+	tests := []struct {
+		name          string
+		message       Message
+		wantCommand   string
+		wantArgs      []string
+		wantErr       bool
+		wantOtherBot  bool
+		wantAddressed bool
+	}{
+		{
+			name:          "command for this bot",
+			message:       Message{Text: "/top@thisbot party", Entities: command(0, 12)},
+			wantCommand:   "top",
+			wantArgs:      []string{"party"},
+			wantAddressed: true,
+		},
+		{
+			name:         "command for other bot",
+			message:      Message{Text: "/start@otherbot", Entities: command(0, 15)},
+			wantCommand:  "start",
+			wantOtherBot: true,
+			// such messages do not reach BotImplementation
+			wantAddressed: true,
+		},
+		{
+			name:    "non-leading command",
+			message: Message{Text: "see /top@otherbot", Entities: command(4, 13)},
+		},
+		{
+			name:        "bare unknown command in group",
+			message:     Message{Text: "/juggle 3 balls", Entities: command(0, 7)},
+			wantCommand: "juggle",
+			wantArgs:    []string{"3", "balls"},
+		},
+		{
+			name:        "arguments after emoji",
+			message:     Message{Text: "/white 🟦 logic", Entities: command(0, 6)},
+			wantCommand: "white",
+			wantArgs:    []string{"🟦", "logic"},
+		},
+		{name: "plain text", message: Message{Text: "hello"}},
+		{name: "command longer than text", message: Message{Text: "/top", Entities: command(0, 10)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCommand(tt.message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.wantCommand {
+				t.Errorf("command = %q, want %q", got, tt.wantCommand)
+			}
+			if args := ParseCommandArgs(tt.message); len(args)+len(tt.wantArgs) > 0 && !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %q, want %q", args, tt.wantArgs)
+			}
+			if other := bot.addressedToOtherBot(tt.message); other != tt.wantOtherBot {
+				t.Errorf("addressed to other bot = %v, want %v", other, tt.wantOtherBot)
+			}
+			if addressed := IsCommandAddressed(tt.message); addressed != tt.wantAddressed {
+				t.Errorf("addressed = %v, want %v", addressed, tt.wantAddressed)
+			}
+		})
+	}
+}
@@ -10,9 +10,37 @@ type ApiResponse struct {
 }
 
 type Update struct {
-	UpdateID      int            `json:"update_id"`
-	Message       *Message       `json:"message,omitempty"`
-	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+	UpdateID           int                 `json:"update_id"`
+	Message            *Message            `json:"message,omitempty"`
+	CallbackQuery      *CallbackQuery      `json:"callback_query,omitempty"`
+	InlineQuery        *InlineQuery        `json:"inline_query,omitempty"`
+	ChosenInlineResult *ChosenInlineResult `json:"chosen_inline_result,omitempty"`
+}
+
+// sender of the update of any kind
+func (this *Update) Sender() *User {
+	switch {
+	case this.CallbackQuery != nil:
+		return this.CallbackQuery.Sender
+	case this.InlineQuery != nil:
+		return this.InlineQuery.Sender
+	case this.ChosenInlineResult != nil:
+		return this.ChosenInlineResult.Sender
+	case this.Message != nil:
+		return this.Message.Sender
+	}
+	return nil
+}
+
+// chat of the update, zero for inline queries, which are not bound to a chat
+func (this *Update) ChatID() int64 {
+	switch {
+	case this.CallbackQuery != nil && this.CallbackQuery.Message != nil:
+		return this.CallbackQuery.Message.Chat.ID
+	case this.Message != nil:
+		return this.Message.Chat.ID
+	}
+	return 0
 }
 
 type Message struct {
@@ -22,12 +50,47 @@ type Message struct {
 	Chat        *Chat                 `json:"chat"`
 	Text        string                `json:"text,omitempty"`
 	Entities    []MessageEntity       `json:"entities,omitempty"`
+	Document    *Document             `json:"document,omitempty"`
+	Photo       []PhotoSize           `json:"photo,omitempty"`
+	Caption     string                `json:"caption,omitempty"`
+	Location    *Location             `json:"location,omitempty"`
 	ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
 }
 
+type Location struct {
+	Longitude float64 `json:"longitude"`
+	Latitude  float64 `json:"latitude"`
+}
+
+type Document struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+}
+
+// one size of a photo, telegram sends several sizes, the largest one last
+type PhotoSize struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	FileSize     int64  `json:"file_size,omitempty"`
+}
+
+type File struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileSize     int64  `json:"file_size,omitempty"`
+	FilePath     string `json:"file_path,omitempty"`
+}
+
 type User struct {
-	ID       int64  `json:"id"`
-	UserName string `json:"username,omitempty"`
+	ID           int64  `json:"id"`
+	UserName     string `json:"username,omitempty"`
+	FirstName    string `json:"first_name"`
+	LanguageCode string `json:"language_code,omitempty"`
 }
 
 type MessageEntity struct {
@@ -44,6 +107,22 @@ type CallbackQuery struct {
 	Data            string   `json:"data,omitempty"`
 }
 
+type InlineQuery struct {
+	ID       string `json:"id"`
+	Sender   *User  `json:"from"`
+	Query    string `json:"query"`
+	Offset   string `json:"offset"`
+	ChatType string `json:"chat_type,omitempty"`
+}
+
+// sent only if inline feedback is enabled for the bot
+type ChosenInlineResult struct {
+	ResultID        string `json:"result_id"`
+	Sender          *User  `json:"from"`
+	InlineMessageID string `json:"inline_message_id,omitempty"`
+	Query           string `json:"query"`
+}
+
 type RequestUpdates struct {
 	Offset         int      `json:"offset,omitempty"`
 	Limit          int      `json:"limit,omitempty"`
@@ -54,12 +133,26 @@ type RequestUpdates struct {
 type InlineKeyboardButton struct {
 	Text         string `json:"text"`
 	CallbackData string `json:"callback_data,omitempty"`
+	URL          string `json:"url,omitempty"`
 }
 
 type InlineKeyboardMarkup struct {
 	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
 }
 
+type KeyboardButton struct {
+	Text            string `json:"text"`
+	RequestLocation bool   `json:"request_location,omitempty"`
+}
+
+// keyboard replacing the usual one, RemoveKeyboard hides it instead
+type ReplyKeyboardMarkup struct {
+	Keyboard        [][]KeyboardButton `json:"keyboard,omitempty"`
+	ResizeKeyboard  bool               `json:"resize_keyboard,omitempty"`
+	OneTimeKeyboard bool               `json:"one_time_keyboard,omitempty"`
+	RemoveKeyboard  bool               `json:"remove_keyboard,omitempty"`
+}
+
 type SendMessage struct {
 	ChatID      int64                 `json:"chat_id"`
 	Text        string                `json:"text"`
@@ -67,6 +160,20 @@ type SendMessage struct {
 	ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
 }
 
+// photo is file id of a photo already on telegram servers
+type SendPhoto struct {
+	ChatID  int64  `json:"chat_id"`
+	Photo   string `json:"photo"`
+	Caption string `json:"caption,omitempty"`
+}
+
+// the same as SendMessage, but with reply keyboard instead of inline one
+type SendReplyKeyboard struct {
+	ChatID      int64                `json:"chat_id"`
+	Text        string               `json:"text"`
+	ReplyMarkup *ReplyKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
 type EditMessageText struct {
 	ChatID      int64                 `json:"chat_id"`
 	MessageID   int                   `json:"message_id"`
@@ -76,8 +183,13 @@ type EditMessageText struct {
 }
 
 type Chat struct {
-	ID   int64  `json:"id"`
-	Type string `json:"type"`
+	ID    int64  `json:"id"`
+	Type  string `json:"type"`
+	Title string `json:"title,omitempty"`
+}
+
+type GetFile struct {
+	FileID string `json:"file_id"`
 }
 
 type AnswerCallbackQuery struct {
@@ -85,3 +197,55 @@ type AnswerCallbackQuery struct {
 	Text            string `json:"text,omitempty"`
 	ShowAlert       bool   `json:"show_alert,omitempty"`
 }
+
+type AnswerInlineQuery struct {
+	InlineQueryID string                     `json:"inline_query_id"`
+	Results       []InlineQueryResultArticle `json:"results"`
+	CacheTime     int                        `json:"cache_time,omitempty"`
+	IsPersonal    bool                       `json:"is_personal,omitempty"`
+	Button        *InlineQueryResultsButton  `json:"button,omitempty"`
+}
+
+// button above inline results, opens private chat with the bot
+type InlineQueryResultsButton struct {
+	Text           string `json:"text"`
+	StartParameter string `json:"start_parameter"`
+}
+
+type InlineQueryResultArticle struct {
+	Type                string                  `json:"type"`
+	ID                  string                  `json:"id"`
+	Title               string                  `json:"title"`
+	Description         string                  `json:"description,omitempty"`
+	InputMessageContent InputTextMessageContent `json:"input_message_content"`
+	ReplyMarkup         *InlineKeyboardMarkup   `json:"reply_markup,omitempty"`
+}
+
+type InputTextMessageContent struct {
+	MessageText string          `json:"message_text"`
+	Entities    []MessageEntity `json:"entities,omitempty"`
+}
+
+type BotCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+// chats where commands are shown, e.g. all private chats
+type BotCommandScope struct {
+	Type string `json:"type"`
+}
+
+type SetMyCommands struct {
+	Commands     []BotCommand     `json:"commands"`
+	Scope        *BotCommandScope `json:"scope,omitempty"`
+	LanguageCode string           `json:"language_code,omitempty"`
+}
+
+// the same parameters select commands to get or to delete
+type GetMyCommands struct {
+	Scope        *BotCommandScope `json:"scope,omitempty"`
+	LanguageCode string           `json:"language_code,omitempty"`
+}
+
+type DeleteMyCommands GetMyCommands
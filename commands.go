@@ -0,0 +1,116 @@
+package main
+
+import (
+	"discocheckbot/api"
+	"discocheckbot/i18n"
+	"slices"
+)
+
+// descriptions of commands are in i18n catalogs as "command.<name>"
+type botCommand struct {
+	name    string
+	listed  int
+	handler func(bot *api.Bot, msg *api.Message) error
+}
+
+func (this botCommand) listedIn(private bool) bool {
+	switch this.listed {
+	case listedInPrivate:
+		return private
+	case listedInGroups:
+		return !private
+	case listedForAdmins:
+		return false
+	}
+	return true
+}
+
+// all commands of the bot in order of menus and /help
+func (this *DiscoCheckBot) newCommandRegistry() []botCommand {
+	return []botCommand{
+		{start, listedInPrivate, this.handleStart},
+		{help, listedEverywhere, this.displayHelp},
+		{addWhite, listedEverywhere, func(bot *api.Bot, msg *api.Message) error {
+			return this.startNewCheck(bot, msg, addWhite, typRetriable)
+		}},
+		{addRed, listedEverywhere, func(bot *api.Bot, msg *api.Message) error {
+			return this.startNewCheck(bot, msg, addRed, typNonRetriable)
+		}},
+		{seeTop, listedEverywhere, this.displayListChecks},
+		{seeLeaderboard, listedInGroups, this.displayLeaderboard},
+		{seeCabinet, listedEverywhere, this.displayCabinet},
+		{useTemplate, listedEverywhere, this.displayTemplates},
+		{importChecks, listedInPrivate, this.displayImportHelp},
+		{setLanguage, listedEverywhere, this.displayLanguages},
+		{setTimezone, listedEverywhere, this.displayTimezones},
+		{adminStats, listedForAdmins, this.displayAdminStats},
+		{broadcast, listedForAdmins, this.startBroadcast},
+		{ban, listedForAdmins, this.banUser},
+		{unban, listedForAdmins, this.unbanUser},
+		{inspect, listedForAdmins, this.inspectCheck},
+	}
+}
+
+func (this *DiscoCheckBot) findCommand(name string) (botCommand, bool) {
+	if i := slices.IndexFunc(this.commands, func(cmd botCommand) bool { return cmd.name == name }); i >= 0 {
+		return this.commands[i], true
+	}
+	return botCommand{}, false
+}
+
+// PublishCommands shows commands in telegram clients, separately for private and group chats,
+// in every language of the catalogs, and in english for other languages.
+// Lists which are already up to date are not sent again.
+func (this *DiscoCheckBot) PublishCommands(bot *api.Bot) error {
+	// commands of default scope would be shown in chats where they are not handled
+	if _, err := bot.DeleteMyCommands(api.DeleteMyCommands{Scope: &api.BotCommandScope{Type: api.DefaultScope}}); err != nil {
+		return err
+	}
+	for _, language := range append([]string{""}, i18n.Default.Languages()...) {
+		lc := newLocale(language)
+		for _, scope := range []string{api.PrivateChatsScope, api.GroupChatsScope} {
+			cmds := getMyCommands(lc, language, scope, this.commands)
+			published, err := bot.GetMyCommands(api.GetMyCommands{Scope: cmds.Scope, LanguageCode: language})
+			if err != nil {
+				return err
+			}
+			if slices.Equal(published, cmds.Commands) {
+				continue
+			}
+			if _, err = bot.SetMyCommands(cmds); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// start link opens or copies a check, plain /start greets the user
+func (this *DiscoCheckBot) handleStart(bot *api.Bot, msg *api.Message) error {
+	if args := api.ParseCommandArgs(*msg); len(args) > 0 && args[0] != inlineStartPayload {
+		return this.handleStartLink(bot, msg, args[0])
+	}
+	bot.SendMessage(getStartMessage(this.locale(msg.Sender), msg.Chat.ID))
+	return nil
+}
+
+func (this *DiscoCheckBot) displayHelp(bot *api.Bot, msg *api.Message) error {
+	bot.SendMessage(getHelpMessage(this.locale(msg.Sender), msg.Chat.ID, this.commands, msg.Chat.Type == api.PrivateChat,
+		this.isAdmin(msg.Sender.ID)))
+	return nil
+}
+
+func (this *DiscoCheckBot) displayImportHelp(bot *api.Bot, msg *api.Message) error {
+	bot.SendMessage(getImportHelpMessage(this.locale(msg.Sender), msg.Chat.ID))
+	return nil
+}
+
+func (this *DiscoCheckBot) displayLanguages(bot *api.Bot, msg *api.Message) error {
+	bot.SendMessage(getLanguageMessage(this.locale(msg.Sender), msg.Chat.ID, i18n.Default.Languages()))
+	return nil
+}
+
+func (this *DiscoCheckBot) displayTimezones(bot *api.Bot, msg *api.Message) error {
+	bot.SendMessage(getTimezoneMessage(this.locale(msg.Sender), msg.Chat.ID, msg.Chat.Type == api.PrivateChat))
+	return nil
+}
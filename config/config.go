@@ -6,32 +6,128 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// environment variables override parameters, e.g. DISCOCHECKBOT_BOT_TOKEN sets bot_token,
+// and DISCOCHECKBOT_BOT_TOKEN_FILE sets it to the content of the file, as docker secrets are mounted
+const (
+	EnvPrefix string = "DISCOCHECKBOT_"
+	EnvFile   string = "_FILE"
 )
 
 type ConfigReader struct {
-	config map[string]interface{}
+	config      map[string]interface{}
+	path        string
+	defaults    map[string]interface{}
+	mutex       sync.RWMutex
+	subscribers []Subscriber
 }
 
-func NewConfigReader(path string) (*ConfigReader, error) {
+// parameters are layered: defaults, then JSON file, then environment variables,
+// then files named by environment variables, empty path skips the JSON file
+func NewConfigReader(path string, defaults map[string]interface{}) (*ConfigReader, error) {
+	reader := ConfigReader{config: make(map[string]interface{}), path: path, defaults: defaults}
+	for name, value := range defaults {
+		reader.config[name] = value
+	}
+	if path != "" {
+		if err := reader.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := reader.readEnv(os.Environ(), os.ReadFile); err != nil {
+		return nil, err
+	}
+	return &reader, nil
+}
+
+func (configReader *ConfigReader) readFile(path string) error {
 	jsonConfig, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer jsonConfig.Close()
 	byteConfig, err := io.ReadAll(jsonConfig)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	var fileConfig map[string]interface{}
+	if err = json.Unmarshal(byteConfig, &fileConfig); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	for name, value := range fileConfig {
+		configReader.config[name] = value
+	}
+	return nil
+}
+
+// variables pointing to files are applied last, so a secret file wins over a plain variable
+func (configReader *ConfigReader) readEnv(environ []string, readFile func(name string) ([]byte, error)) error {
+	files := make(map[string]string)
+	for _, variable := range environ {
+		key, value, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(key, EnvPrefix) {
+			continue
+		}
+		name := strings.ToLower(strings.TrimPrefix(key, EnvPrefix))
+		if strings.HasSuffix(key, EnvFile) {
+			files[strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(key, EnvPrefix), EnvFile))] = value
+			continue
+		}
+		if err := configReader.setString(name, value); err != nil {
+			return fmt.Errorf("environment variable %s: %w", key, err)
+		}
+	}
+	for name, path := range files {
+		content, err := readFile(path)
+		if err != nil {
+			return fmt.Errorf("environment variable %s%s%s: %w", EnvPrefix, strings.ToUpper(name), EnvFile, err)
+		}
+		// editors and echo leave trailing newline in secret files
+		if err = configReader.setString(name, strings.TrimRight(string(content), "\r\n")); err != nil {
+			return fmt.Errorf("environment variable %s%s%s: %w", EnvPrefix, strings.ToUpper(name), EnvFile, err)
+		}
+	}
+	return nil
+}
+
+// text is converted to the type of the value it overrides, e.g. "5432" to number for db_port,
+// new parameters are strings, unless they are JSON arrays or objects
+func (configReader *ConfigReader) setString(name string, text string) error {
+	var value interface{}
+	var err error
+	switch prev := configReader.config[name]; prev.(type) {
+	case float64:
+		value, err = strconv.ParseFloat(strings.TrimSpace(text), 64)
+	case bool:
+		value, err = strconv.ParseBool(strings.TrimSpace(text))
+	case string:
+		value = text
+	case nil:
+		if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+			err = json.Unmarshal([]byte(trimmed), &value)
+		} else {
+			value = text
+		}
+	default:
+		err = json.Unmarshal([]byte(text), &value)
+		if err == nil && reflect.TypeOf(value) != reflect.TypeOf(prev) {
+			err = fmt.Errorf("parameter %q must be %T", name, prev)
+		}
 	}
-	var reader ConfigReader
-	err = json.Unmarshal(byteConfig, &reader.config)
 	if err != nil {
-		return nil, err
-	} else {
-		return &reader, nil
+		return err
 	}
+	configReader.config[name] = value
+	return nil
 }
 
 func (configReader *ConfigReader) GetParameter(name string, valuePtr interface{}) error {
+	configReader.mutex.RLock()
+	defer configReader.mutex.RUnlock()
 	if configValue, ok := configReader.config[name]; ok && configValue != nil {
 		configValuePtrType := reflect.PointerTo(reflect.TypeOf(configValue))
 		valuePtrType := reflect.TypeOf(valuePtr)
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewConfigReader(t *testing.T) {
+	defaults := map[string]interface{}{
+		"db_host":     "localhost",
+		"db_port":     float64(5432),
+		"log_redact":  true,
+		"admin_ids":   []interface{}{float64(1)},
+		"bot_token":   "default",
+		"db_password": "default",
+	}
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		secrets map[string]string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "defaults only",
+			want: map[string]interface{}{"db_host": "localhost", "db_port": float64(5432), "bot_token": "default"},
+		},
+		{
+			name: "file over defaults",
+			file: `{"db_host": "db", "bot_token": "from file"}`,
+			want: map[string]interface{}{"db_host": "db", "db_port": float64(5432), "bot_token": "from file"},
+		},
+		{
+			name: "env over file",
+			file: `{"db_host": "db", "db_port": 6432}`,
+			env:  map[string]string{"DISCOCHECKBOT_DB_HOST": "env", "DISCOCHECKBOT_DB_PORT": " 7432 "},
+			want: map[string]interface{}{"db_host": "env", "db_port": float64(7432)},
+		},
+		{
+			name: "env converted to type of default",
+			env: map[string]string{
+				"DISCOCHECKBOT_LOG_REDACT": "false",
+				"DISCOCHECKBOT_ADMIN_IDS":  "[2, 3]",
+				"DISCOCHECKBOT_NEW_LIST":   "[4]",
+				"DISCOCHECKBOT_NEW_TEXT":   "text",
+			},
+			want: map[string]interface{}{
+				"log_redact": false,
+				"admin_ids":  []interface{}{float64(2), float64(3)},
+				"new_list":   []interface{}{float64(4)},
+				"new_text":   "text",
+			},
+		},
+		{
+			name:    "file variable over plain variable",
+			file:    `{"bot_token": "from file"}`,
+			env:     map[string]string{"DISCOCHECKBOT_BOT_TOKEN": "env"},
+			secrets: map[string]string{"DISCOCHECKBOT_BOT_TOKEN_FILE": "secret\n"},
+			want:    map[string]interface{}{"bot_token": "secret", "db_password": "default"},
+		},
+		{
+			name: "other variables ignored",
+			env:  map[string]string{"DB_HOST": "other", "DISCOCHECKBOTDB_HOST": "other"},
+			want: map[string]interface{}{"db_host": "localhost"},
+		},
+		{name: "broken file", file: `{"db_host": `, wantErr: true},
+		{name: "number not parsed", env: map[string]string{"DISCOCHECKBOT_DB_PORT": "port"}, wantErr: true},
+		{name: "list of wrong type", env: map[string]string{"DISCOCHECKBOT_ADMIN_IDS": `{"id": 1}`}, wantErr: true},
+		{name: "missing secret file", env: map[string]string{"DISCOCHECKBOT_DB_PASSWORD_FILE": "/nonexistent/secret"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var path string
+			if tt.file != "" {
+				path = filepath.Join(dir, "config.json")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			for key, content := range tt.secrets {
+				secret := filepath.Join(dir, key)
+				if err := os.WriteFile(secret, []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv(key, secret)
+			}
+			reader, err := NewConfigReader(path, defaults)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("error is expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for name, want := range tt.want {
+				if got := reader.config[name]; !reflect.DeepEqual(got, want) {
+					t.Errorf("parameter %s is %#v, want %#v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetParameter(t *testing.T) {
+	reader, err := NewConfigReader("", map[string]interface{}{"db_host": "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var host string
+	if err = reader.GetParameter("db_host", &host); err != nil || host != "localhost" {
+		t.Errorf("got %q, %v", host, err)
+	}
+	var port int
+	if err = reader.GetParameter("db_host", &port); err == nil {
+		t.Error("parameter is read into pointer of another type")
+	}
+	if err = reader.GetParameter("db_port", &port); err == nil {
+		t.Error("missing parameter is read")
+	}
+}
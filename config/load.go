@@ -0,0 +1,188 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parameters of the bot, see Load for meaning of tags
+type Config struct {
+	BotToken             string  `config:"bot_token" immutable:"true"`
+	LongPollingTimeout   int     `config:"long_polling_timeout" default:"60" min:"1"`
+	RequestUpdatesRetry  int     `config:"request_updates_retry" default:"5" min:"1"`
+	UpdatesLimit         int     `config:"updates_limit" default:"100" min:"1" max:"100"`
+	DbHost               string  `config:"db_host" default:"localhost" immutable:"true"`
+	DbPort               int     `config:"db_port" default:"5432" min:"1" max:"65535" immutable:"true"`
+	DbUser               string  `config:"db_user" immutable:"true"`
+	DbPassword           string  `config:"db_password" immutable:"true"`
+	DbName               string  `config:"db_name" immutable:"true"`
+	AllowedIds           []int64 `config:"allowed_ids,optional" immutable:"true"`
+	DeniedIds            []int64 `config:"denied_ids,optional" immutable:"true"`
+	AdminIds             []int64 `config:"admin_ids,optional" immutable:"true"`
+	UndoWindow           int     `config:"undo_window" default:"300" min:"0" immutable:"true"`
+	UserUpdatesPerMinute float64 `config:"user_updates_per_minute" default:"30" min:"1"`
+	UserUpdatesBurst     int     `config:"user_updates_burst" default:"10" min:"1"`
+	ChatUpdatesPerMinute float64 `config:"chat_updates_per_minute" default:"60" min:"1"`
+	ChatUpdatesBurst     int     `config:"chat_updates_burst" default:"20" min:"1"`
+	LogFormat            string  `config:"log_format" default:"text" oneof:"text json" immutable:"true"`
+	LogLevel             string  `config:"log_level" default:"info" oneof:"debug info warn error"`
+	LogRedact            bool    `config:"log_redact" default:"true" immutable:"true"`
+	HttpAddress          string  `config:"http_address,optional" immutable:"true"`
+}
+
+// Load fills fields of the struct by their tags:
+// config - name of the parameter, with ",optional" if it may be missing,
+// default - value of missing parameter, min and max - range of numbers,
+// oneof - allowed values of string separated by spaces,
+// immutable - the parameter is not changed by reload, see Watch.
+// Strings are converted to numbers, as they come from environment variables.
+// All problems are reported at once.
+func (configReader *ConfigReader) Load(target interface{}) error {
+	strucVal := reflect.ValueOf(target)
+	if strucVal.Kind() != reflect.Pointer || strucVal.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config can be loaded only into pointer to struct, passed %T", target)
+	}
+	strucVal = strucVal.Elem()
+	strucType := strucVal.Type()
+	var errs []error
+	for i := 0; i < strucType.NumField(); i++ {
+		field := strucType.Field(i)
+		name, flag, _ := strings.Cut(field.Tag.Get("config"), ",")
+		if name == "" {
+			continue
+		}
+		configReader.mutex.RLock()
+		value, ok := configReader.config[name]
+		configReader.mutex.RUnlock()
+		if !ok || value == nil {
+			if defValue, ok := field.Tag.Lookup("default"); ok {
+				value = defValue
+			} else if flag == "optional" {
+				continue
+			} else {
+				errs = append(errs, fmt.Errorf("parameter %q is not found", name))
+				continue
+			}
+		}
+		if err := setField(strucVal.Field(i), value); err != nil {
+			errs = append(errs, fmt.Errorf("parameter %q %w", name, err))
+			continue
+		}
+		if err := checkRange(strucVal.Field(i), field.Tag); err != nil {
+			errs = append(errs, fmt.Errorf("parameter %q %w", name, err))
+		}
+		if err := checkOneOf(strucVal.Field(i), field.Tag); err != nil {
+			errs = append(errs, fmt.Errorf("parameter %q %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// errors are worded to follow the name of the parameter, e.g. "must be a number, found "abc""
+func setField(field reflect.Value, value interface{}) error {
+	switch field.Kind() {
+	case reflect.String:
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("must be a string, found %v", value)
+		}
+		field.SetString(text)
+	case reflect.Bool:
+		switch typed := value.(type) {
+		case bool:
+			field.SetBool(typed)
+		case string:
+			flag, err := strconv.ParseBool(strings.TrimSpace(typed))
+			if err != nil {
+				return fmt.Errorf("must be true or false, found %q", typed)
+			}
+			field.SetBool(flag)
+		default:
+			return fmt.Errorf("must be true or false, found %v", value)
+		}
+	case reflect.Int, reflect.Int64:
+		number, err := toNumber(value)
+		if err != nil {
+			return err
+		}
+		if number != math.Trunc(number) {
+			return fmt.Errorf("must be a whole number, found %v", number)
+		}
+		field.SetInt(int64(number))
+	case reflect.Float64:
+		number, err := toNumber(value)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(number)
+	case reflect.Slice:
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("must be a list, found %v", value)
+		}
+		slice := reflect.MakeSlice(field.Type(), len(list), len(list))
+		for i, item := range list {
+			if err := setField(slice.Index(i), item); err != nil {
+				return fmt.Errorf("item %d %w", i, err)
+			}
+		}
+		field.Set(slice)
+	default:
+		return fmt.Errorf("has unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// json number interprets as float64, environment variable as string
+func toNumber(value interface{}) (float64, error) {
+	switch typed := value.(type) {
+	case float64:
+		return typed, nil
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(typed), 64)
+		if err != nil {
+			return 0, fmt.Errorf("must be a number, found %q", typed)
+		}
+		return number, nil
+	}
+	return 0, fmt.Errorf("must be a number, found %v", value)
+}
+
+func checkRange(field reflect.Value, tag reflect.StructTag) error {
+	var number float64
+	switch field.Kind() {
+	case reflect.Int, reflect.Int64:
+		number = float64(field.Int())
+	case reflect.Float64:
+		number = field.Float()
+	default:
+		return nil
+	}
+	if min, ok := tag.Lookup("min"); ok {
+		if limit, err := strconv.ParseFloat(min, 64); err == nil && number < limit {
+			return fmt.Errorf("must be at least %s, found %v", min, number)
+		}
+	}
+	if max, ok := tag.Lookup("max"); ok {
+		if limit, err := strconv.ParseFloat(max, 64); err == nil && number > limit {
+			return fmt.Errorf("must be at most %s, found %v", max, number)
+		}
+	}
+	return nil
+}
+
+func checkOneOf(field reflect.Value, tag reflect.StructTag) error {
+	values, ok := tag.Lookup("oneof")
+	if !ok || field.Kind() != reflect.String {
+		return nil
+	}
+	if !slices.Contains(strings.Fields(values), field.String()) {
+		return fmt.Errorf("must be one of %s, found %q", strings.Join(strings.Fields(values), ", "), field.String())
+	}
+	return nil
+}
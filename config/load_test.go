@@ -0,0 +1,125 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testConfig struct {
+	Token   string   `config:"token"`
+	Port    int      `config:"port" default:"5432" min:"1" max:"65535"`
+	Rate    float64  `config:"rate" default:"1.5" min:"0.5"`
+	Redact  bool     `config:"redact" default:"true"`
+	Format  string   `config:"format" default:"text" oneof:"text json"`
+	Ids     []int64  `config:"ids,optional"`
+	Names   []string `config:"names,optional"`
+	Ignored string
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		want    testConfig
+		wantErr []string
+	}{
+		{
+			name:   "defaults",
+			config: map[string]interface{}{"token": "secret"},
+			want:   testConfig{Token: "secret", Port: 5432, Rate: 1.5, Redact: true, Format: "text"},
+		},
+		{
+			name: "json values",
+			config: map[string]interface{}{
+				"token":  "secret",
+				"port":   float64(6432),
+				"rate":   float64(2),
+				"redact": false,
+				"format": "json",
+				"ids":    []interface{}{float64(1), float64(-2)},
+				"names":  []interface{}{"a"},
+			},
+			want: testConfig{Token: "secret", Port: 6432, Rate: 2, Redact: false, Format: "json", Ids: []int64{1, -2}, Names: []string{"a"}},
+		},
+		{
+			name:   "strings of environment",
+			config: map[string]interface{}{"token": "secret", "port": " 6432", "rate": "0.5", "redact": "false"},
+			want:   testConfig{Token: "secret", Port: 6432, Rate: 0.5, Redact: false, Format: "text"},
+		},
+		{
+			name:   "null is missing",
+			config: map[string]interface{}{"token": "secret", "port": nil, "ids": nil},
+			want:   testConfig{Token: "secret", Port: 5432, Rate: 1.5, Redact: true, Format: "text"},
+		},
+		{name: "required missing", config: map[string]interface{}{}, wantErr: []string{`"token" is not found`}},
+		{
+			name:    "below min",
+			config:  map[string]interface{}{"token": "secret", "port": float64(0), "rate": float64(0.1)},
+			wantErr: []string{`"port" must be at least 1`, `"rate" must be at least 0.5`},
+		},
+		{
+			name:    "above max",
+			config:  map[string]interface{}{"token": "secret", "port": float64(70000)},
+			wantErr: []string{`"port" must be at most 65535`},
+		},
+		{
+			name:    "not one of",
+			config:  map[string]interface{}{"token": "secret", "format": "xml"},
+			wantErr: []string{`"format" must be one of text, json, found "xml"`},
+		},
+		{
+			name:    "wrong types",
+			config:  map[string]interface{}{"token": float64(1), "port": "port", "redact": "maybe", "ids": []interface{}{"one"}},
+			wantErr: []string{`"token" must be a string`, `"port" must be a number`, `"redact" must be true or false`, `"ids" item 0 must be a number`},
+		},
+		{
+			name:    "fraction for whole number",
+			config:  map[string]interface{}{"token": "secret", "port": float64(80.5)},
+			wantErr: []string{`"port" must be a whole number`},
+		},
+		{
+			name:    "every problem reported",
+			config:  map[string]interface{}{"port": float64(0), "format": "xml", "names": "a"},
+			wantErr: []string{`"token" is not found`, `"port" must be at least 1`, `"format" must be one of`, `"names" must be a list`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := ConfigReader{config: tt.config}
+			var got testConfig
+			err := reader.Load(&got)
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatal("error is expected")
+				}
+				joined, ok := err.(interface{ Unwrap() []error })
+				if !ok || len(joined.Unwrap()) != len(tt.wantErr) {
+					t.Fatalf("error %q does not report %d problems", err, len(tt.wantErr))
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q does not contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTarget(t *testing.T) {
+	reader := ConfigReader{config: map[string]interface{}{}}
+	var cfg testConfig
+	for _, target := range []interface{}{cfg, &cfg.Port, nil} {
+		if err := reader.Load(target); err == nil {
+			t.Errorf("target %T is loaded", target)
+		}
+	}
+}
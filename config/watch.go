@@ -0,0 +1,108 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// receives config after reload, immutable parameters of both are the same
+type Subscriber func(prev *Config, next *Config)
+
+func (configReader *ConfigReader) Subscribe(subscriber Subscriber) {
+	configReader.mutex.Lock()
+	defer configReader.mutex.Unlock()
+	configReader.subscribers = append(configReader.subscribers, subscriber)
+}
+
+// Watch reloads config when its file is modified or the process gets SIGHUP,
+// and passes it to subscribers, if anything has changed.
+// Invalid config is ignored, changes of immutable parameters are ignored with a warning.
+// Meant to be run in its own goroutine, cfg is the config loaded at start.
+func (configReader *ConfigReader) Watch(cfg Config, interval time.Duration, log *slog.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	modTime := configReader.modTime()
+	for {
+		select {
+		case <-signals:
+			log.Info("reloading config on SIGHUP")
+		case <-ticker.C:
+			if mt := configReader.modTime(); !mt.Equal(modTime) {
+				modTime = mt
+				log.Info("reloading config, file is modified", "path", configReader.path)
+			} else {
+				continue
+			}
+		}
+		if next, ok := configReader.reload(cfg, log); ok {
+			cfg = next
+		}
+	}
+}
+
+// zero time if there is no file, so its appearance is a modification too
+func (configReader *ConfigReader) modTime() time.Time {
+	if configReader.path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(configReader.path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func (configReader *ConfigReader) reload(prev Config, log *slog.Logger) (Config, bool) {
+	fresh, err := NewConfigReader(configReader.path, configReader.defaults)
+	var next Config
+	if err == nil {
+		err = fresh.Load(&next)
+	}
+	if err != nil {
+		log.Warn("config is not reloaded", "error", err)
+		return prev, false
+	}
+	prevVal := reflect.ValueOf(&prev).Elem()
+	nextVal := reflect.ValueOf(&next).Elem()
+	var immutable []string
+	for i := 0; i < prevVal.NumField(); i++ {
+		field := prevVal.Type().Field(i)
+		if field.Tag.Get("immutable") != "true" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("config"), ",")
+		immutable = append(immutable, name)
+		if !reflect.DeepEqual(prevVal.Field(i).Interface(), nextVal.Field(i).Interface()) {
+			log.Warn("parameter is changed, restart to apply it", "parameter", name)
+			nextVal.Field(i).Set(prevVal.Field(i))
+		}
+	}
+	configReader.mutex.Lock()
+	// GetParameter keeps returning values the bot was started with
+	for _, name := range immutable {
+		if value, ok := configReader.config[name]; ok {
+			fresh.config[name] = value
+		} else {
+			delete(fresh.config, name)
+		}
+	}
+	configReader.config = fresh.config
+	subscribers := configReader.subscribers
+	configReader.mutex.Unlock()
+	if reflect.DeepEqual(prev, next) {
+		log.Info("config is reloaded without changes")
+		return next, true
+	}
+	for _, subscriber := range subscribers {
+		subscriber(&prev, &next)
+	}
+	log.Info("config is reloaded")
+	return next, true
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReloadKeepsImmutable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"bot_token": "old", "db_user": "bot", "db_password": "old", "db_name": "checks", "log_level": "info"}`)
+	reader, err := NewConfigReader(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	if err = reader.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	var notified *Config
+	reader.Subscribe(func(prev *Config, next *Config) { notified = next })
+	write(`{"bot_token": "new", "db_user": "bot", "db_password": "new", "db_name": "checks", "log_level": "debug", "http_address": ":8080"}`)
+	next, ok := reader.reload(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if !ok || notified == nil {
+		t.Fatal("config is not reloaded")
+	}
+	if next.LogLevel != "debug" || next.BotToken != "old" || next.DbPassword != "old" || next.HttpAddress != "" {
+		t.Errorf("reloaded config is %+v", next)
+	}
+	for name, want := range map[string]string{"bot_token": "old", "db_password": "old", "log_level": "debug"} {
+		var got string
+		if err = reader.GetParameter(name, &got); err != nil || got != want {
+			t.Errorf("parameter %s is %q, %v, want %q", name, got, err, want)
+		}
+	}
+	var address string
+	if err = reader.GetParameter("http_address", &address); err == nil {
+		t.Errorf("rejected parameter http_address is %q", address)
+	}
+}
@@ -1,16 +1,103 @@
 package main
 
+import (
+	"discocheckbot/i18n"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	reminderLead         time.Duration = time.Hour
+	reminderPollInterval time.Duration = time.Minute
+	maxRemindersAtPoll   int           = 50
+	// failed reminder is retried later, so it does not hold the ones after it, and dropped at last
+	reminderRetryDelay  time.Duration = 10 * time.Minute
+	maxReminderFailures int           = 5
+	configPollInterval  time.Duration = 5 * time.Second
+	httpReadTimeout     time.Duration = 10 * time.Second
+	dbPingTimeout       time.Duration = 3 * time.Second
+	// telegram allows about 30 messages a second to different chats
+	broadcastInterval time.Duration = 50 * time.Millisecond
+	// ban is a block, which does not end in practice
+	banDuration time.Duration = 100 * 365 * 24 * time.Hour
+	// allowance over polling timeouts before the bot is considered stuck or not ready
+	healthGrace time.Duration = time.Minute
+	// unanswered prompt, e.g. for a due date or a note, is forgotten, so later messages are handled as usual
+	promptTimeout time.Duration = 10 * time.Minute
+)
+
+// strikes are updates of the user over the limits, which are in config
+const (
+	spamStrikesWindow    time.Duration = 10 * time.Minute
+	spamStrikesToBlock   int           = 30
+	spamBlockDuration    time.Duration = time.Hour
+	maxSpamBlockDuration time.Duration = 7 * 24 * time.Hour
+	// the next block after such a quiet period is the first one again
+	spamBlockForgottenAfter time.Duration = 30 * 24 * time.Hour
+	maxSpamStrikers         int           = 1000
+)
+
 const (
 	maxChecksAtListPage int = 9
 	maxCheckBtnInRow    int = 3
+	maxImportPreview    int = 5
+	maxLeaderboardRows  int = 5
+	maxDueDaysInPicker  int = 9
+	maxCabinetSlots     int = 3
+	maxInlineResults    int = 20
+	// seconds, inline results are personal and change with attempts
+	inlineCacheTime int = 10
+	// limited by telegram
+	maxCbqAnswerLength int = 200
+	// limited by checks.description column
+	maxDescriptionLength int = 100
+	// limited by blocks.reason column
+	maxBlockReasonLength int = 100
+	// limited by attempts.note column
+	maxNoteLength int = 500
+	// per user or chat, templates are listed as buttons
+	maxTemplates int = 20
+	// limited by templates.name column
+	maxTemplateNameLength int = 30
 )
 
 // commands
 const (
-	start    string = "start"
-	addWhite string = "white"
-	addRed   string = "red"
-	seeTop   string = "top"
+	start          string = "start"
+	help           string = "help"
+	addWhite       string = "white"
+	addRed         string = "red"
+	seeTop         string = "top"
+	importChecks   string = "import"
+	seeLeaderboard string = "leaderboard"
+	setDue         string = "due"
+	seeCabinet     string = "cabinet"
+	setLanguage    string = "language"
+	setTimezone    string = "timezone"
+	useTemplate    string = "templates"
+	adminStats     string = "admin_stats"
+	broadcast      string = "broadcast"
+	ban            string = "ban"
+	unban          string = "unban"
+	inspect        string = "inspect"
+)
+
+// chats where command is listed in telegram clients, it is handled in any chat anyway
+const (
+	listedEverywhere = iota
+	listedInPrivate
+	listedInGroups
+	// not listed and handled only for admin_ids of config
+	listedForAdmins
+)
+
+// command arguments
+const (
+	partyFlag string = "party"
+	// start parameter of links in shared checks
+	inlineStartPayload string = "inline"
 )
 
 // skill identifiers
@@ -41,7 +128,7 @@ const (
 	motComposure
 )
 
-// skill texts
+// skill names, as used in imported files and voices, displayed texts are in i18n catalogs
 var skillNames = [25]string{
 	"",
 	"🟦 Logic",
@@ -70,7 +157,41 @@ var skillNames = [25]string{
 	"🟨 Composure",
 }
 
-var skillDescriptions = [25]string{}
+// identifier of the name in lists of names, e.g. skillNames, or of its text in any language of the catalogs,
+// catalogKey is the prefix of catalog keys, e.g. "skill.", so "logic" and "🟦 Логика" are intLogic
+func lookupName(value string, catalogKey string, names ...[]string) (int, bool) {
+	key := normalizeName(value)
+	for _, list := range names {
+		for id, name := range list {
+			if name != "" && normalizeName(name) == key {
+				return id, true
+			}
+		}
+	}
+	for _, language := range i18n.Default.Languages() {
+		lc := newLocale(language)
+		for id, name := range names[0] {
+			if name == "" {
+				continue
+			}
+			if normalizeName(lc.T(catalogKey+strconv.Itoa(id))) == key {
+				return id, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// strips emojis and punctuation, so "🟦 Logic" matches "logic"
+func normalizeName(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == ' ' {
+			sb.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
 
 // skill difficulty identifiers
 const (
@@ -85,7 +206,7 @@ const (
 	difImpossible
 )
 
-// skill difficulty texts
+// skill difficulty names, as used in imported files
 var difficultyNames = [10]string{
 	"",
 	"Trivial",
@@ -105,14 +226,26 @@ const (
 	resCanceled
 	resFailure
 	resSuccess
+	// period of recurring instance ended without closing attempt, set by the bot only
+	resMissed
 )
 
-// check result texts
-var resultNames = [4]string{
+// check result names, as used in imported files
+var resultNames = [5]string{
 	"",
 	"Cancel 🚫",
 	"Failure 🔴",
 	"Success 🟢",
+	"Missed ⏰",
+}
+
+// check result labels of metrics
+var resultLabels = [5]string{
+	"",
+	"canceled",
+	"failure",
+	"success",
+	"missed",
 }
 
 const (
@@ -120,16 +253,182 @@ const (
 	typRetriable
 )
 
-// check type texts
+// check type names, as used in imported files
 var typeNames = [3]string{
 	"",
 	"Red check",
 	"White check",
 }
 
+// check type labels of metrics
+var typeLabels = [3]string{
+	"",
+	"red",
+	"white",
+}
+
+// reminder kinds
+const (
+	remBeforeDue = iota + 1
+	remOverdue
+	remRecur // opens next instance of recurring check
+)
+
+const (
+	dueSkip = iota
+	duePick
+)
+
+const (
+	importConfirm = iota
+	importCancel
+)
+
+const (
+	broadcastConfirm = iota
+	broadcastCancel
+)
+
 const (
 	listCheckDetail = iota
 	listCheckForward
 	listCheckBackward
 	listCheckAction
+	listCheckUndo
+	listCheckChange
+	listCheckNote
+	listCheckPhoto
+	listCheckTemplate
+)
+
+const (
+	templateUse = iota
+	templateDelete
+)
+
+// leaderboard period identifiers
+const (
+	periodWeek = iota
+	periodMonth
+	periodAll
+)
+
+// leaderboard period lengths, 0 is unlimited
+var periodDays = [3]int{
+	7,
+	30,
+	0,
+}
+
+// thought identifiers
+const (
+	thoughtVolumetric = iota + 1
+	thoughtHobocop
+	thoughtJamaisVu
+	thoughtFeminist
+	thoughtLonesome
+	thoughtIndotribe
+	thoughtWompty
+	thoughtFingerPistols
+	thoughtSelfCritique
+)
+
+// thoughts of the cabinet
+var thoughtDefs = [10]thoughtDef{
+	{},
+	{
+		ResearchChecks: 5,
+		Researching:    []skillModifier{{intRhetoric, -1}},
+		Internalized:   []skillModifier{{intLogic, 1}, {intRhetoric, 1}},
+	},
+	{
+		ResearchDays: 3,
+		Researching:  []skillModifier{{psyAuthority, -1}},
+		Internalized: []skillModifier{{phyShivers, 1}, {phyEndurance, 1}},
+	},
+	{
+		ResearchDays: 2,
+		Researching:  []skillModifier{{motPerception, -1}},
+		Internalized: []skillModifier{{intConcept, 1}, {psyInland, 1}},
+	},
+	{
+		ResearchChecks: 3,
+		Researching:    []skillModifier{{psyEmpathy, -1}},
+		Internalized:   []skillModifier{{psyEmpathy, 1}, {psySuggestion, 1}},
+	},
+	{
+		ResearchDays: 5,
+		Researching:  []skillModifier{{psyEsprit, -1}},
+		Internalized: []skillModifier{{phyEndurance, 1}, {psyVolition, 1}},
+	},
+	{
+		ResearchChecks: 4,
+		Researching:    []skillModifier{{intLogic, -1}},
+		Internalized:   []skillModifier{{intEncyclopedia, 1}, {intDrama, -1}},
+	},
+	{
+		ResearchChecks: 6,
+		Researching:    []skillModifier{{intEncyclopedia, -1}},
+		Internalized:   []skillModifier{{intRhetoric, 2}, {psyEmpathy, -1}},
+	},
+	{
+		ResearchChecks: 2,
+		Researching:    []skillModifier{{motComposure, -1}},
+		Internalized:   []skillModifier{{motCoordintation, 1}, {motSavoir, 1}},
+	},
+	{
+		ResearchDays: 4,
+		Researching:  []skillModifier{{psyVolition, -1}},
+		Internalized: []skillModifier{{psyVolition, 2}, {phyElectrochem, -1}},
+	},
+}
+
+// cabinet operations
+const (
+	cabinetView = iota
+	cabinetChoose
+	cabinetInternalize
+	cabinetForget
+)
+
+// language operations
+const (
+	languageAuto = iota
+	languageSet
+)
+
+// time zone operations
+const (
+	timezoneCity = iota
+	timezoneFormat
+	timezoneLocation
+)
+
+// start link actions
+const (
+	linkOpen = iota
+	linkClone
 )
+
+// time zones offered with /timezone, names of cities are in i18n catalogs
+var timezoneCities = [...]string{
+	"America/Los_Angeles",
+	"America/Denver",
+	"America/Chicago",
+	"America/New_York",
+	"UTC",
+	"Europe/London",
+	"Europe/Berlin",
+	"Europe/Kyiv",
+	"Europe/Moscow",
+	"Asia/Yekaterinburg",
+	"Asia/Novosibirsk",
+	"Asia/Vladivostok",
+}
+
+// date format names, the first one is default
+var dateFormats = [...]string{
+	"dmy",
+	"mdy",
+	"ymd",
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"reflect"
 	"slices"
+	"time"
 
 	_ "github.com/lib/pq"
 )
 
+// instance of recurring check for the due date is opened already, e.g. by a retried reminder
+var errInstanceExists = errors.New("instance of the series is already opened")
+
 type psqlAdapter struct {
 	connStr string
 }
@@ -35,6 +40,18 @@ func (this *psqlAdapter) connect() (*sql.DB, error) {
 	return sql.Open("postgres", this.connStr)
 }
 
+// checks that database answers, for readiness of the bot
+func (this *psqlAdapter) ping() error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), dbPingTimeout)
+	defer cancel()
+	return conn.PingContext(ctx)
+}
+
 func (this *psqlAdapter) createCheck(chk *check) error {
 	conn, err := this.connect()
 	if err != nil {
@@ -50,24 +67,37 @@ func (this *psqlAdapter) createCheck(chk *check) error {
 			created_at,
 			created_by_user,
 			created_by_message,
-			created_by_chat
+			created_by_chat,
+			party,
+			due_at,
+			recurrence,
+			series_id
 			) VALUES (
 			$1, $2, $3, $4, 
-			now()::timestamp,
-			$5, $6, $7
-		) RETURNING check_id;`,
+			now(),
+			$5, $6, $7, $8,
+			$9, nullif($10, ''), nullif($11, 0)
+		) ON CONFLICT (series_id, due_at) WHERE series_id IS NOT NULL DO NOTHING
+		RETURNING check_id;`,
 		chk.Skill,
 		chk.Typ,
 		chk.Difficulty,
 		chk.Description,
 		chk.CreatedByUser,
 		chk.CreatedByMessage,
-		chk.CreatedByChat)
+		chk.CreatedByChat,
+		chk.Party,
+		nullTime(chk.DueAt),
+		chk.Recurrence,
+		chk.SeriesId)
 	if err != nil {
 		return err
 	}
 	defer res.Close()
 	if !res.Next() {
+		if chk.SeriesId != 0 {
+			return errInstanceExists
+		}
 		return errors.New("insert checks not successful, no id returned")
 	}
 	res.Scan(&chk.Id)
@@ -85,15 +115,17 @@ func (this *psqlAdapter) createAttempt(att *attempt) error {
 			check_id,
 			result,
 			created_at,
+			created_by_user,
 			created_by_message,
 			created_by_chat
 			) VALUES (
 			$1, $2,
-			now()::timestamp,
-			$3, $4
-		) RETURNING attempt_id;`,
+			now(),
+			$3, $4, $5
+		) RETURNING attempt_id, created_at;`,
 		att.CheckId,
 		att.Result,
+		att.CreatedByUser,
 		att.CreatedByMessage,
 		att.CreatedByChat)
 	if err != nil {
@@ -103,11 +135,215 @@ func (this *psqlAdapter) createAttempt(att *attempt) error {
 	if !res.Next() {
 		return errors.New("insert attempts not successful, no id returned")
 	}
-	res.Scan(&att.Id)
+	res.Scan(&att.Id, &att.CreatedAt)
 	return nil
 }
 
+// deleted attempt is not found
+func (this *psqlAdapter) readAttempt(attemptId int64) (attempt, error) {
+	conn, err := this.connect()
+	if err != nil {
+		return attempt{}, err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(
+		`SELECT
+			attempt_id,
+			check_id,
+			result,
+			note,
+			photo_file_id,
+			created_at AS a_created_at,
+			created_by_user AS a_created_by_user,
+			created_by_chat,
+			created_by_message
+		 FROM attempts
+		 WHERE attempt_id = $1
+		 AND deleted_at IS NULL;`,
+		attemptId)
+	if err != nil {
+		return attempt{}, err
+	}
+	defer rows.Close()
+	var result attempt
+	if rows.Next() {
+		if err = moveCorresponding(rows, &result); err != nil {
+			return attempt{}, err
+		}
+	}
+	if result.Id == 0 {
+		return result, fmt.Errorf("attempt %d not found", attemptId)
+	}
+	return result, rows.Err()
+}
+
+// empty note or photo removes it
+func (this *psqlAdapter) setAttemptNote(attemptId int64, note string, photoId string) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Exec(
+		`UPDATE attempts
+		 SET note = nullif($2, ''),
+		 photo_file_id = nullif($3, '')
+		 WHERE attempt_id = $1
+		 AND deleted_at IS NULL;`,
+		attemptId,
+		note,
+		photoId)
+	return err
+}
+
+// attempt is kept for history, but no longer counts, so its check is open again
+func (this *psqlAdapter) deleteAttempt(attemptId int64, userId int64) error {
+	return this.changeAttempt(attemptId, userId,
+		`UPDATE attempts a
+		 SET deleted_at = now()
+		 FROM attempts prev
+		 WHERE a.attempt_id = prev.attempt_id
+		 AND a.attempt_id = $1
+		 AND a.deleted_at IS NULL
+		 RETURNING prev.result, NULL::INTEGER;`,
+		attemptId)
+}
+
+func (this *psqlAdapter) changeAttemptResult(attemptId int64, result int, userId int64) error {
+	return this.changeAttempt(attemptId, userId,
+		`UPDATE attempts a
+		 SET result = $2
+		 FROM attempts prev
+		 WHERE a.attempt_id = prev.attempt_id
+		 AND a.attempt_id = $1
+		 AND a.deleted_at IS NULL
+		 RETURNING prev.result, a.result;`,
+		attemptId,
+		result)
+}
+
+// applies the update returning results before and after it, and records them in history,
+// result after undo is null
+func (this *psqlAdapter) changeAttempt(attemptId int64, userId int64, update string, args ...any) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var before int
+	var after sql.NullInt64
+	if err = tx.QueryRow(update, args...).Scan(&before, &after); err == sql.ErrNoRows {
+		return fmt.Errorf("attempt %d not found", attemptId)
+	} else if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO attempt_history (
+			attempt_id,
+			result_before,
+			result_after,
+			changed_by_user,
+			changed_at
+			) VALUES (
+			$1, $2, $3, $4,
+			now()
+		);`,
+		attemptId,
+		before,
+		after,
+		userId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// inserts all checks with their attempts in one transaction
+func (this *psqlAdapter) importChecks(list []check) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i := range list {
+		chk := &list[i]
+		err = tx.QueryRow(
+			`INSERT INTO checks (
+				skill,
+				type,
+				difficulty,
+				description,
+				created_at,
+				created_by_user,
+				created_by_message,
+				created_by_chat
+				) VALUES (
+				$1, $2, $3, $4,
+				coalesce($5, now()),
+				$6, $7, $8
+			) RETURNING check_id;`,
+			chk.Skill,
+			chk.Typ,
+			chk.Difficulty,
+			chk.Description,
+			nullTime(chk.CreatedAt),
+			chk.CreatedByUser,
+			chk.CreatedByMessage,
+			chk.CreatedByChat).Scan(&chk.Id)
+		if err != nil {
+			return err
+		}
+		for j := range chk.Attempts {
+			att := &chk.Attempts[j]
+			att.CheckId = chk.Id
+			err = tx.QueryRow(
+				`INSERT INTO attempts (
+					check_id,
+					result,
+					created_at,
+					created_by_user,
+					created_by_message,
+					created_by_chat
+					) VALUES (
+					$1, $2,
+					coalesce($3, now()),
+					$4, $5, $6
+				) RETURNING attempt_id;`,
+				att.CheckId,
+				att.Result,
+				nullTime(att.CreatedAt),
+				att.CreatedByUser,
+				att.CreatedByMessage,
+				att.CreatedByChat).Scan(&att.Id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// personal checks of the user, party checks are listed with the chat
 func (this *psqlAdapter) listUserChecks(userId int64, offsetId int64, desc bool) ([]check, error) {
+	return this.listChecks("c.created_by_user = $1 AND NOT c.party", userId, offsetId, desc)
+}
+
+func (this *psqlAdapter) listChatChecks(chatId int64, offsetId int64, desc bool) ([]check, error) {
+	return this.listChecks("c.created_by_chat = $1 AND c.party", chatId, offsetId, desc)
+}
+
+// filter is applied to checks and receives ownerId as $1
+func (this *psqlAdapter) listChecks(filter string, ownerId int64, offsetId int64, desc bool) ([]check, error) {
 	var dynClause string
 	if desc {
 		dynClause = `WHERE u.updated_at > coalesce((
@@ -138,8 +374,9 @@ func (this *psqlAdapter) listUserChecks(userId int64, offsetId int64, desc bool)
 		  	FROM checks c
 			LEFT JOIN attempts a
 			ON c.check_id = a.check_id
-			WHERE c.created_by_user = $1
-			ORDER BY check_id, updated_at DESC
+			AND a.deleted_at IS NULL
+			WHERE `+filter+`
+			ORDER BY check_id, updated_at DESC, a.attempt_id DESC
 		)
 		SELECT 
 			c.check_id,
@@ -147,11 +384,13 @@ func (this *psqlAdapter) listUserChecks(userId int64, offsetId int64, desc bool)
 			c.difficulty,
 			c.type,
 			c.description,
+			c.party,
+			c.due_at,
 			u.result
 		FROM checks c 
 		JOIN check_updates u 
 		ON c.check_id = u.check_id `+dynClause+` LIMIT $3;`,
-		userId,
+		ownerId,
 		offsetId,
 		maxChecksAtListPage)
 	if err != nil {
@@ -179,6 +418,63 @@ func (this *psqlAdapter) listUserChecks(userId int64, offsetId int64, desc bool)
 	return result, nil
 }
 
+// open checks created by the user, personal and party ones, with description containing the text
+func (this *psqlAdapter) searchOpenChecks(userId int64, text string, limit int) ([]check, error) {
+	conn, err := this.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(
+		`WITH last_attempts AS (
+			SELECT DISTINCT ON(a.check_id)
+				a.check_id,
+				a.result
+			FROM attempts a
+			JOIN checks c
+			ON a.check_id = c.check_id
+			WHERE c.created_by_user = $1
+			AND a.deleted_at IS NULL
+			ORDER BY a.check_id, a.created_at DESC, a.attempt_id DESC
+		)
+		SELECT
+			c.check_id,
+			c.skill,
+			c.difficulty,
+			c.type,
+			c.description,
+			c.party,
+			c.due_at,
+			c.recurrence,
+			c.created_at
+		FROM checks c
+		LEFT JOIN last_attempts a
+		ON c.check_id = a.check_id
+		WHERE c.created_by_user = $1
+		AND (a.result IS NULL OR a.result = $2 AND c.type = $3)
+		AND strpos(lower(c.description), lower($4)) > 0
+		ORDER BY c.created_at DESC
+		LIMIT $5;`,
+		userId,
+		resFailure,
+		typRetriable,
+		text,
+		limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]check, 0)
+	for rows.Next() {
+		chk := check{}
+		if err := moveCorresponding(rows, &chk); err != nil {
+			return nil, err
+		}
+		result = append(result, chk)
+	}
+	return result, nil
+}
+
 func (this *psqlAdapter) readCheck(checkId int64) (check, error) {
 	conn, err := this.connect()
 	if err != nil {
@@ -192,14 +488,26 @@ func (this *psqlAdapter) readCheck(checkId int64) (check, error) {
 			c.difficulty,
 			c.type,
 			c.description,
+			c.party,
+			c.due_at,
+			c.recurrence,
+			coalesce(c.series_id, c.check_id) AS series_id,
 			c.created_at,
+			c.created_by_user,
+			c.created_by_chat,
+			c.created_by_message,
+			a.attempt_id,
 			coalesce(a.result,0) AS result,
-			a.created_at AS a_created_at
+			a.note,
+			a.photo_file_id,
+			a.created_at AS a_created_at,
+			a.created_by_user AS a_created_by_user
 		 FROM checks c
 		 LEFT JOIN attempts a
 		 ON c.check_id = a.check_id
+		 AND a.deleted_at IS NULL
 		 WHERE c.check_id = $1
-		 ORDER BY a_created_at;`,
+		 ORDER BY a_created_at, a.attempt_id;`,
 		checkId)
 	if err != nil {
 		return check{}, err
@@ -226,6 +534,708 @@ func (this *psqlAdapter) readCheck(checkId int64) (check, error) {
 	return result, nil
 }
 
+// instances of recurring check with their last attempts, newest first
+func (this *psqlAdapter) listSeriesInstances(seriesId int64) ([]check, error) {
+	conn, err := this.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(
+		`SELECT DISTINCT ON (c.check_id)
+			c.check_id,
+			c.type,
+			c.skill,
+			c.difficulty,
+			c.due_at,
+			c.recurrence,
+			coalesce(a.result,0) AS result,
+			a.created_at AS a_created_at
+		 FROM checks c
+		 LEFT JOIN attempts a
+		 ON c.check_id = a.check_id
+		 AND a.deleted_at IS NULL
+		 WHERE coalesce(c.series_id, c.check_id) = $1
+		 ORDER BY c.check_id DESC, a.created_at DESC, a.attempt_id DESC;`,
+		seriesId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]check, 0)
+	for rows.Next() {
+		chk := check{}
+		if err := moveCorresponding(rows, &chk); err != nil {
+			return nil, err
+		}
+		att := attempt{}
+		if err := moveCorresponding(rows, &att); err != nil {
+			return nil, err
+		}
+		if att.Result != resDefault {
+			chk.Attempts = append(chk.Attempts, att)
+		}
+		result = append(result, chk)
+	}
+	return result, rows.Err()
+}
+
+func (this *psqlAdapter) createReminder(rem *reminder) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.QueryRow(
+		`INSERT INTO reminders (
+			check_id,
+			kind,
+			remind_at
+			) VALUES (
+			$1, $2,
+			$3
+		) RETURNING reminder_id;`,
+		rem.CheckId,
+		rem.Kind,
+		rem.RemindAt).Scan(&rem.Id)
+}
+
+// reminders which time has come, but that were not sent yet
+func (this *psqlAdapter) listPendingReminders(limit int) ([]reminder, error) {
+	conn, err := this.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(
+		`SELECT
+			reminder_id,
+			check_id,
+			kind,
+			remind_at
+		 FROM reminders
+		 WHERE NOT sent
+		 AND remind_at <= now()
+		 ORDER BY remind_at
+		 LIMIT $1;`,
+		limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]reminder, 0)
+	for rows.Next() {
+		rem := reminder{}
+		if err := moveCorresponding(rows, &rem); err != nil {
+			return nil, err
+		}
+		result = append(result, rem)
+	}
+	return result, rows.Err()
+}
+
+// pending reminders of the kind are not sent, e.g. next instance after undo of the last attempt
+func (this *psqlAdapter) cancelReminders(checkId int64, kind int) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Exec(
+		`UPDATE reminders SET sent = true WHERE check_id = $1 AND kind = $2 AND NOT sent;`,
+		checkId,
+		kind)
+	return err
+}
+
+func (this *psqlAdapter) markReminderSent(reminderId int64) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Exec(
+		`UPDATE reminders SET sent = true WHERE reminder_id = $1;`,
+		reminderId)
+	return err
+}
+
+// reminder is retried after delay, it is given up as sent after maxFailures
+func (this *psqlAdapter) postponeReminder(reminderId int64, delay time.Duration, maxFailures int) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Exec(
+		`UPDATE reminders
+		 SET failures = failures + 1,
+		 remind_at = now() + make_interval(secs => $2),
+		 sent = failures + 1 >= $3
+		 WHERE reminder_id = $1;`,
+		reminderId,
+		delay.Seconds(),
+		maxFailures)
+	return err
+}
+
+// thoughts in the cabinet, both researching and internalized
+func (this *psqlAdapter) readCabinet(userId int64) ([]cabinetThought, error) {
+	conn, err := this.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(
+		`SELECT
+			cabinet_id,
+			user_id,
+			thought,
+			slot,
+			progress,
+			started_at,
+			finished_at
+		 FROM cabinet
+		 WHERE user_id = $1
+		 AND NOT forgotten
+		 ORDER BY slot;`,
+		userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]cabinetThought, 0)
+	for rows.Next() {
+		ct := cabinetThought{}
+		if err := moveCorresponding(rows, &ct); err != nil {
+			return nil, err
+		}
+		result = append(result, ct)
+	}
+	return result, rows.Err()
+}
+
+func (this *psqlAdapter) createCabinetThought(ct *cabinetThought) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.QueryRow(
+		`INSERT INTO cabinet (
+			user_id,
+			thought,
+			slot,
+			progress,
+			started_at
+			) VALUES (
+			$1, $2, $3, 0,
+			now()
+		) RETURNING cabinet_id, started_at;`,
+		ct.UserId,
+		ct.Thought,
+		ct.Slot).Scan(&ct.Id, &ct.StartedAt)
+}
+
+func (this *psqlAdapter) finishCabinetThought(ct *cabinetThought) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.QueryRow(
+		`UPDATE cabinet
+		 SET finished_at = now()
+		 WHERE cabinet_id = $1
+		 RETURNING finished_at;`,
+		ct.Id).Scan(&ct.FinishedAt)
+}
+
+func (this *psqlAdapter) forgetCabinetThought(userId int64, slot int) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	res, err := conn.Exec(
+		`UPDATE cabinet
+		 SET forgotten = true
+		 WHERE user_id = $1
+		 AND slot = $2
+		 AND NOT forgotten;`,
+		userId,
+		slot)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("slot %d is already empty", slot)
+	}
+	return nil
+}
+
+// counts completed checks for all thoughts being researched by the user
+func (this *psqlAdapter) advanceResearch(userId int64) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Exec(
+		`UPDATE cabinet
+		 SET progress = progress + 1
+		 WHERE user_id = $1
+		 AND finished_at IS NULL
+		 AND NOT forgotten;`,
+		userId)
+	return err
+}
+
+// takes back progress of a check which is no longer completed, finished thoughts stay finished
+func (this *psqlAdapter) retreatResearch(userId int64) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Exec(
+		`UPDATE cabinet
+		 SET progress = greatest(progress - 1, 0)
+		 WHERE user_id = $1
+		 AND finished_at IS NULL
+		 AND NOT forgotten;`,
+		userId)
+	return err
+}
+
+// settings chosen by user are kept, they are changed by setUser... methods only
+func (this *psqlAdapter) saveUser(usr *user) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var language, timezone, dateFormat sql.NullString
+	err = conn.QueryRow(
+		`INSERT INTO users (
+			user_id,
+			user_name,
+			first_name,
+			language_code,
+			updated_at
+			) VALUES (
+			$1, $2, $3, $4,
+			now()
+		) ON CONFLICT (user_id) DO UPDATE SET
+			user_name = excluded.user_name,
+			first_name = excluded.first_name,
+			language_code = excluded.language_code,
+			updated_at = excluded.updated_at
+		RETURNING language, timezone, date_format;`,
+		usr.Id,
+		usr.UserName,
+		usr.FirstName,
+		usr.LanguageCode).Scan(&language, &timezone, &dateFormat)
+	usr.Language = language.String
+	usr.Timezone = timezone.String
+	usr.DateFormat = dateFormat.String
+	return err
+}
+
+func (this *psqlAdapter) readUser(userId int64) (user, error) {
+	conn, err := this.connect()
+	if err != nil {
+		return user{}, err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(
+		`SELECT
+			user_id,
+			user_name,
+			first_name,
+			language_code,
+			language,
+			timezone,
+			date_format,
+			updated_at
+		 FROM users
+		 WHERE user_id = $1;`,
+		userId)
+	if err != nil {
+		return user{}, err
+	}
+	defer rows.Close()
+	usr := user{Id: userId}
+	if rows.Next() {
+		if err = moveCorresponding(rows, &usr); err != nil {
+			return user{}, err
+		}
+	}
+	return usr, rows.Err()
+}
+
+// empty language means automatic choice by telegram settings
+func (this *psqlAdapter) setUserLanguage(userId int64, language string) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Exec(
+		`UPDATE users SET language = nullif($2, '') WHERE user_id = $1;`,
+		userId,
+		language)
+	return err
+}
+
+// empty timezone means server time zone
+func (this *psqlAdapter) setUserTimezone(userId int64, timezone string) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Exec(
+		`UPDATE users SET timezone = nullif($2, '') WHERE user_id = $1;`,
+		userId,
+		timezone)
+	return err
+}
+
+func (this *psqlAdapter) setUserDateFormat(userId int64, dateFormat string) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Exec(
+		`UPDATE users SET date_format = nullif($2, '') WHERE user_id = $1;`,
+		userId,
+		dateFormat)
+	return err
+}
+
+func (this *psqlAdapter) createTemplate(tmpl *template) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	res, err := conn.Query(
+		`INSERT INTO templates (
+			name,
+			skill,
+			type,
+			difficulty,
+			description,
+			party,
+			created_at,
+			created_by_user,
+			created_by_chat
+			) VALUES (
+			$1, $2, $3, $4, $5, $6,
+			now(),
+			$7, $8
+		) RETURNING template_id;`,
+		tmpl.Name,
+		tmpl.Skill,
+		tmpl.Typ,
+		tmpl.Difficulty,
+		tmpl.Description,
+		tmpl.Party,
+		tmpl.CreatedByUser,
+		tmpl.CreatedByChat)
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+	if !res.Next() {
+		return errors.New("insert templates not successful, no id returned")
+	}
+	res.Scan(&tmpl.Id)
+	return nil
+}
+
+func (this *psqlAdapter) readTemplate(templateId int64) (template, error) {
+	list, err := this.listTemplates("template_id = $1", templateId)
+	if err != nil {
+		return template{}, err
+	}
+	if len(list) == 0 {
+		return template{}, fmt.Errorf("template %d not found", templateId)
+	}
+	return list[0], nil
+}
+
+func (this *psqlAdapter) listUserTemplates(userId int64) ([]template, error) {
+	return this.listTemplates("created_by_user = $1 AND NOT party", userId)
+}
+
+func (this *psqlAdapter) listChatTemplates(chatId int64) ([]template, error) {
+	return this.listTemplates("created_by_chat = $1 AND party", chatId)
+}
+
+// filter is applied to templates and receives id as $1
+func (this *psqlAdapter) listTemplates(filter string, id int64) ([]template, error) {
+	conn, err := this.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(
+		`SELECT
+			template_id,
+			name,
+			skill,
+			type,
+			difficulty,
+			description,
+			party,
+			created_at,
+			created_by_user,
+			created_by_chat
+		 FROM templates
+		 WHERE `+filter+`
+		 ORDER BY name, template_id;`,
+		id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]template, 0)
+	for rows.Next() {
+		var tmpl template
+		if err = moveCorresponding(rows, &tmpl); err != nil {
+			return nil, err
+		}
+		result = append(result, tmpl)
+	}
+	return result, rows.Err()
+}
+
+// only the author deletes the template, also the shared one
+func (this *psqlAdapter) deleteTemplate(templateId int64, userId int64) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	res, err := conn.Exec(
+		`DELETE FROM templates
+		 WHERE template_id = $1
+		 AND created_by_user = $2;`,
+		templateId,
+		userId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("template %d of user %d not found", templateId, userId)
+	}
+	return nil
+}
+
+func (this *psqlAdapter) saveChat(cht *chat) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Exec(
+		`INSERT INTO chats (
+			chat_id,
+			type,
+			title,
+			updated_at
+			) VALUES (
+			$1, $2, $3,
+			now()
+		) ON CONFLICT (chat_id) DO UPDATE SET
+			type = excluded.type,
+			title = excluded.title,
+			updated_at = excluded.updated_at;`,
+		cht.Id,
+		cht.Type,
+		cht.Title)
+	return err
+}
+
+func (this *psqlAdapter) listChatIds() ([]int64, error) {
+	conn, err := this.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(`SELECT chat_id FROM chats ORDER BY chat_id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]int64, 0)
+	for rows.Next() {
+		var chatId int64
+		if err = rows.Scan(&chatId); err != nil {
+			return nil, err
+		}
+		result = append(result, chatId)
+	}
+	return result, rows.Err()
+}
+
+func (this *psqlAdapter) readStats() (botStats, error) {
+	conn, err := this.connect()
+	if err != nil {
+		return botStats{}, err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(
+		`SELECT
+			(SELECT count(*) FROM users) AS users,
+			(SELECT count(*) FROM chats) AS chats,
+			(SELECT count(*) FROM checks) AS checks,
+			(SELECT count(*) FROM attempts WHERE deleted_at IS NULL) AS attempts;`)
+	if err != nil {
+		return botStats{}, err
+	}
+	defer rows.Close()
+	var stats botStats
+	if rows.Next() {
+		if err = moveCorresponding(rows, &stats); err != nil {
+			return botStats{}, err
+		}
+	}
+	return stats, rows.Err()
+}
+
+// the previous block of the user is locked while the next one is made from it, see block.extend
+func (this *psqlAdapter) blockUser(blk *block, now time.Time, duration time.Duration, maxDuration time.Duration, forgetAfter time.Duration) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var previous block
+	err = tx.QueryRow(
+		`SELECT blocked_until, times FROM blocks WHERE user_id = $1 FOR UPDATE;`,
+		blk.UserId).Scan(&previous.BlockedUntil, &previous.Times)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	blk.extend(previous, now, duration, maxDuration, forgetAfter)
+	_, err = tx.Exec(
+		`INSERT INTO blocks (
+			user_id,
+			blocked_until,
+			times,
+			reason
+			) VALUES (
+			$1, $2, $3, $4
+		) ON CONFLICT (user_id) DO UPDATE SET
+			blocked_until = excluded.blocked_until,
+			times = excluded.times,
+			reason = excluded.reason;`,
+		blk.UserId,
+		blk.BlockedUntil,
+		blk.Times,
+		blk.Reason)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// history of blocks is kept, so the next block is longer anyway, unless it is forgotten
+func (this *psqlAdapter) unblockUser(userId int64) error {
+	conn, err := this.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Exec(
+		`UPDATE blocks SET blocked_until = now() WHERE user_id = $1 AND blocked_until > now();`,
+		userId)
+	return err
+}
+
+func (this *psqlAdapter) listActiveBlocks() ([]block, error) {
+	conn, err := this.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(
+		`SELECT
+			user_id,
+			blocked_until,
+			times,
+			reason
+		 FROM blocks
+		 WHERE blocked_until > now();`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]block, 0)
+	for rows.Next() {
+		blk := block{}
+		if err := moveCorresponding(rows, &blk); err != nil {
+			return nil, err
+		}
+		result = append(result, blk)
+	}
+	return result, nil
+}
+
+// aggregates attempts on checks of the chat made during last periodDays, 0 means all time
+func (this *psqlAdapter) readChatLeaderboard(chatId int64, periodDays int) ([]leaderboardRow, error) {
+	conn, err := this.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(
+		`SELECT
+			a.created_by_user AS user_id,
+			coalesce(u.user_name,'') AS user_name,
+			coalesce(u.first_name,'') AS first_name,
+			count(*) FILTER (WHERE a.result = $3) AS successes,
+			coalesce(sum(c.difficulty) FILTER (WHERE a.result = $3),0) AS weighted_successes,
+			coalesce(sum(c.difficulty) FILTER (WHERE a.result IN ($3,$4)),0) AS weighted_attempts,
+			coalesce(max(c.difficulty) FILTER (WHERE a.result = $3),0) AS hardest
+		 FROM attempts a
+		 JOIN checks c
+		 ON a.check_id = c.check_id
+		 LEFT JOIN users u
+		 ON a.created_by_user = u.user_id
+		 WHERE c.created_by_chat = $1
+		 AND a.created_by_user IS NOT NULL
+		 AND a.deleted_at IS NULL
+		 AND ($2 = 0 OR a.created_at >= now() - make_interval(days => $2))
+		 GROUP BY a.created_by_user, u.user_name, u.first_name;`,
+		chatId,
+		periodDays,
+		resSuccess,
+		resFailure)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]leaderboardRow, 0)
+	for rows.Next() {
+		row := leaderboardRow{}
+		if err := moveCorresponding(rows, &row); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
 func (this *psqlAdapter) init() error {
 	conn, err := this.connect()
 	if err != nil {
@@ -239,7 +1249,7 @@ func (this *psqlAdapter) init() error {
 			type INTEGER,
 			difficulty INTEGER,
 			description VARCHAR(100),
-			created_at TIMESTAMP,
+			created_at TIMESTAMPTZ,
 			created_by_user BIGINT,
 			created_by_message BIGINT,
 			created_by_chat BIGINT
@@ -248,13 +1258,98 @@ func (this *psqlAdapter) init() error {
 			attempt_id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
 			check_id BIGINT REFERENCES checks (check_id),
 			result INTEGER,
-			created_at TIMESTAMP,
+			created_at TIMESTAMPTZ,
 			created_by_message BIGINT,
 			created_by_chat BIGINT
+		);
+		ALTER TABLE checks ADD COLUMN IF NOT EXISTS party BOOLEAN NOT NULL DEFAULT false;
+		ALTER TABLE attempts ADD COLUMN IF NOT EXISTS created_by_user BIGINT;
+		CREATE TABLE IF NOT EXISTS users (
+			user_id BIGINT PRIMARY KEY,
+			user_name VARCHAR(32),
+			first_name VARCHAR(64),
+			updated_at TIMESTAMPTZ
+		);
+		ALTER TABLE checks ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ;
+		CREATE TABLE IF NOT EXISTS reminders (
+			reminder_id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+			check_id BIGINT REFERENCES checks (check_id),
+			kind INTEGER,
+			remind_at TIMESTAMPTZ,
+			sent BOOLEAN NOT NULL DEFAULT false
+		);
+		CREATE INDEX IF NOT EXISTS reminders_pending ON reminders (remind_at) WHERE NOT sent;
+		ALTER TABLE reminders ADD COLUMN IF NOT EXISTS failures INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE checks ADD COLUMN IF NOT EXISTS recurrence VARCHAR(40);
+		ALTER TABLE checks ADD COLUMN IF NOT EXISTS series_id BIGINT REFERENCES checks (check_id);
+		CREATE UNIQUE INDEX IF NOT EXISTS checks_series_due ON checks (series_id, due_at) WHERE series_id IS NOT NULL;
+		CREATE TABLE IF NOT EXISTS cabinet (
+			cabinet_id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+			user_id BIGINT,
+			thought INTEGER,
+			slot INTEGER,
+			progress INTEGER,
+			started_at TIMESTAMPTZ,
+			finished_at TIMESTAMPTZ,
+			forgotten BOOLEAN NOT NULL DEFAULT false
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS cabinet_slots ON cabinet (user_id, slot) WHERE NOT forgotten;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS language_code VARCHAR(35);
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS language VARCHAR(8);
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64);
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS date_format VARCHAR(8);
+		-- old values were written by now()::timestamp, so they are in the time zone of the session
+		DO $$
+		BEGIN
+			IF (SELECT data_type FROM information_schema.columns
+				WHERE table_name = 'checks' AND column_name = 'created_at') = 'timestamp without time zone' THEN
+				ALTER TABLE checks ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+				ALTER TABLE attempts ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+				ALTER TABLE users ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
+			END IF;
+		END $$;
+		CREATE TABLE IF NOT EXISTS blocks (
+			user_id BIGINT PRIMARY KEY,
+			blocked_until TIMESTAMPTZ,
+			times INTEGER NOT NULL DEFAULT 0,
+			reason VARCHAR(100)
+		);
+		CREATE TABLE IF NOT EXISTS chats (
+			chat_id BIGINT PRIMARY KEY,
+			type VARCHAR(16),
+			title VARCHAR(255),
+			updated_at TIMESTAMPTZ
+		);
+		ALTER TABLE attempts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+		CREATE TABLE IF NOT EXISTS attempt_history (
+			history_id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+			attempt_id BIGINT REFERENCES attempts (attempt_id),
+			result_before INTEGER,
+			result_after INTEGER,
+			changed_by_user BIGINT,
+			changed_at TIMESTAMPTZ
+		);
+		ALTER TABLE attempts ADD COLUMN IF NOT EXISTS note VARCHAR(500);
+		ALTER TABLE attempts ADD COLUMN IF NOT EXISTS photo_file_id VARCHAR(255);
+		CREATE TABLE IF NOT EXISTS templates (
+			template_id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+			name VARCHAR(30),
+			skill INTEGER,
+			type INTEGER,
+			difficulty INTEGER,
+			description VARCHAR(100),
+			party BOOLEAN NOT NULL DEFAULT false,
+			created_at TIMESTAMPTZ,
+			created_by_user BIGINT,
+			created_by_chat BIGINT
 		);`)
 	return err
 }
 
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
 func moveCorresponding(row *sql.Rows, struc interface{}) error {
 	strucType := reflect.TypeOf(struc).Elem()
 	strucVal := reflect.ValueOf(struc).Elem()
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"discocheckbot/metrics"
+	"time"
+)
+
+var (
+	dbQuerySeconds = metrics.Default.NewHistogramVec("discocheckbot_db_query_duration_seconds",
+		"Time of database queries, by method of the adapter.", metrics.LatencyBuckets, "method")
+	checksCreatedTotal = metrics.Default.NewCounterVec("discocheckbot_checks_created_total",
+		"Checks created, including imported ones.", "type")
+	attemptsCreatedTotal = metrics.Default.NewCounterVec("discocheckbot_attempts_created_total",
+		"Attempts created, including imported ones.", "result")
+)
+
+// measures every method of the adapter and counts created checks and attempts
+type meteredDb struct {
+	db dbAdapter
+}
+
+func (this meteredDb) createCheck(chk *check) error {
+	defer dbQuerySeconds.Since(time.Now(), "createCheck")
+	if err := this.db.createCheck(chk); err != nil {
+		return err
+	}
+	checksCreatedTotal.Inc(typeLabels[chk.Typ])
+	return nil
+}
+
+func (this meteredDb) createAttempt(att *attempt) error {
+	defer dbQuerySeconds.Since(time.Now(), "createAttempt")
+	if err := this.db.createAttempt(att); err != nil {
+		return err
+	}
+	attemptsCreatedTotal.Inc(resultLabels[att.Result])
+	return nil
+}
+
+func (this meteredDb) readAttempt(attemptId int64) (attempt, error) {
+	defer dbQuerySeconds.Since(time.Now(), "readAttempt")
+	return this.db.readAttempt(attemptId)
+}
+
+func (this meteredDb) deleteAttempt(attemptId int64, userId int64) error {
+	defer dbQuerySeconds.Since(time.Now(), "deleteAttempt")
+	return this.db.deleteAttempt(attemptId, userId)
+}
+
+func (this meteredDb) changeAttemptResult(attemptId int64, result int, userId int64) error {
+	defer dbQuerySeconds.Since(time.Now(), "changeAttemptResult")
+	return this.db.changeAttemptResult(attemptId, result, userId)
+}
+
+func (this meteredDb) setAttemptNote(attemptId int64, note string, photoId string) error {
+	defer dbQuerySeconds.Since(time.Now(), "setAttemptNote")
+	return this.db.setAttemptNote(attemptId, note, photoId)
+}
+
+func (this meteredDb) importChecks(list []check) error {
+	defer dbQuerySeconds.Since(time.Now(), "importChecks")
+	if err := this.db.importChecks(list); err != nil {
+		return err
+	}
+	for _, chk := range list {
+		checksCreatedTotal.Inc(typeLabels[chk.Typ])
+		for _, att := range chk.Attempts {
+			attemptsCreatedTotal.Inc(resultLabels[att.Result])
+		}
+	}
+	return nil
+}
+
+func (this meteredDb) init() error {
+	defer dbQuerySeconds.Since(time.Now(), "init")
+	return this.db.init()
+}
+
+func (this meteredDb) listUserChecks(userId int64, offsetId int64, desc bool) ([]check, error) {
+	defer dbQuerySeconds.Since(time.Now(), "listUserChecks")
+	return this.db.listUserChecks(userId, offsetId, desc)
+}
+
+func (this meteredDb) listChatChecks(chatId int64, offsetId int64, desc bool) ([]check, error) {
+	defer dbQuerySeconds.Since(time.Now(), "listChatChecks")
+	return this.db.listChatChecks(chatId, offsetId, desc)
+}
+
+func (this meteredDb) searchOpenChecks(userId int64, text string, limit int) ([]check, error) {
+	defer dbQuerySeconds.Since(time.Now(), "searchOpenChecks")
+	return this.db.searchOpenChecks(userId, text, limit)
+}
+
+func (this meteredDb) readChatLeaderboard(chatId int64, periodDays int) ([]leaderboardRow, error) {
+	defer dbQuerySeconds.Since(time.Now(), "readChatLeaderboard")
+	return this.db.readChatLeaderboard(chatId, periodDays)
+}
+
+func (this meteredDb) saveUser(usr *user) error {
+	defer dbQuerySeconds.Since(time.Now(), "saveUser")
+	return this.db.saveUser(usr)
+}
+
+func (this meteredDb) readUser(userId int64) (user, error) {
+	defer dbQuerySeconds.Since(time.Now(), "readUser")
+	return this.db.readUser(userId)
+}
+
+func (this meteredDb) setUserLanguage(userId int64, language string) error {
+	defer dbQuerySeconds.Since(time.Now(), "setUserLanguage")
+	return this.db.setUserLanguage(userId, language)
+}
+
+func (this meteredDb) setUserTimezone(userId int64, timezone string) error {
+	defer dbQuerySeconds.Since(time.Now(), "setUserTimezone")
+	return this.db.setUserTimezone(userId, timezone)
+}
+
+func (this meteredDb) setUserDateFormat(userId int64, dateFormat string) error {
+	defer dbQuerySeconds.Since(time.Now(), "setUserDateFormat")
+	return this.db.setUserDateFormat(userId, dateFormat)
+}
+
+func (this meteredDb) createReminder(rem *reminder) error {
+	defer dbQuerySeconds.Since(time.Now(), "createReminder")
+	return this.db.createReminder(rem)
+}
+
+func (this meteredDb) listPendingReminders(limit int) ([]reminder, error) {
+	defer dbQuerySeconds.Since(time.Now(), "listPendingReminders")
+	return this.db.listPendingReminders(limit)
+}
+
+func (this meteredDb) markReminderSent(reminderId int64) error {
+	defer dbQuerySeconds.Since(time.Now(), "markReminderSent")
+	return this.db.markReminderSent(reminderId)
+}
+
+func (this meteredDb) cancelReminders(checkId int64, kind int) error {
+	defer dbQuerySeconds.Since(time.Now(), "cancelReminders")
+	return this.db.cancelReminders(checkId, kind)
+}
+
+func (this meteredDb) listSeriesInstances(seriesId int64) ([]check, error) {
+	defer dbQuerySeconds.Since(time.Now(), "listSeriesInstances")
+	return this.db.listSeriesInstances(seriesId)
+}
+
+func (this meteredDb) readCabinet(userId int64) ([]cabinetThought, error) {
+	defer dbQuerySeconds.Since(time.Now(), "readCabinet")
+	return this.db.readCabinet(userId)
+}
+
+func (this meteredDb) createCabinetThought(ct *cabinetThought) error {
+	defer dbQuerySeconds.Since(time.Now(), "createCabinetThought")
+	return this.db.createCabinetThought(ct)
+}
+
+func (this meteredDb) finishCabinetThought(ct *cabinetThought) error {
+	defer dbQuerySeconds.Since(time.Now(), "finishCabinetThought")
+	return this.db.finishCabinetThought(ct)
+}
+
+func (this meteredDb) forgetCabinetThought(userId int64, slot int) error {
+	defer dbQuerySeconds.Since(time.Now(), "forgetCabinetThought")
+	return this.db.forgetCabinetThought(userId, slot)
+}
+
+func (this meteredDb) advanceResearch(userId int64) error {
+	defer dbQuerySeconds.Since(time.Now(), "advanceResearch")
+	return this.db.advanceResearch(userId)
+}
+
+func (this meteredDb) retreatResearch(userId int64) error {
+	defer dbQuerySeconds.Since(time.Now(), "retreatResearch")
+	return this.db.retreatResearch(userId)
+}
+
+func (this meteredDb) readCheck(checkId int64) (check, error) {
+	defer dbQuerySeconds.Since(time.Now(), "readCheck")
+	return this.db.readCheck(checkId)
+}
+
+func (this meteredDb) blockUser(blk *block, now time.Time, duration time.Duration, maxDuration time.Duration, forgetAfter time.Duration) error {
+	defer dbQuerySeconds.Since(time.Now(), "blockUser")
+	return this.db.blockUser(blk, now, duration, maxDuration, forgetAfter)
+}
+
+func (this meteredDb) listActiveBlocks() ([]block, error) {
+	defer dbQuerySeconds.Since(time.Now(), "listActiveBlocks")
+	return this.db.listActiveBlocks()
+}
+
+func (this meteredDb) unblockUser(userId int64) error {
+	defer dbQuerySeconds.Since(time.Now(), "unblockUser")
+	return this.db.unblockUser(userId)
+}
+
+func (this meteredDb) createTemplate(tmpl *template) error {
+	defer dbQuerySeconds.Since(time.Now(), "createTemplate")
+	return this.db.createTemplate(tmpl)
+}
+
+func (this meteredDb) readTemplate(templateId int64) (template, error) {
+	defer dbQuerySeconds.Since(time.Now(), "readTemplate")
+	return this.db.readTemplate(templateId)
+}
+
+func (this meteredDb) listUserTemplates(userId int64) ([]template, error) {
+	defer dbQuerySeconds.Since(time.Now(), "listUserTemplates")
+	return this.db.listUserTemplates(userId)
+}
+
+func (this meteredDb) listChatTemplates(chatId int64) ([]template, error) {
+	defer dbQuerySeconds.Since(time.Now(), "listChatTemplates")
+	return this.db.listChatTemplates(chatId)
+}
+
+func (this meteredDb) deleteTemplate(templateId int64, userId int64) error {
+	defer dbQuerySeconds.Since(time.Now(), "deleteTemplate")
+	return this.db.deleteTemplate(templateId, userId)
+}
+
+func (this meteredDb) saveChat(cht *chat) error {
+	defer dbQuerySeconds.Since(time.Now(), "saveChat")
+	return this.db.saveChat(cht)
+}
+
+func (this meteredDb) listChatIds() ([]int64, error) {
+	defer dbQuerySeconds.Since(time.Now(), "listChatIds")
+	return this.db.listChatIds()
+}
+
+func (this meteredDb) readStats() (botStats, error) {
+	defer dbQuerySeconds.Since(time.Now(), "readStats")
+	return this.db.readStats()
+}
+
+func (this meteredDb) ping() error {
+	defer dbQuerySeconds.Since(time.Now(), "ping")
+	return this.db.ping()
+}
+
+func (this meteredDb) postponeReminder(reminderId int64, delay time.Duration, maxFailures int) error {
+	defer dbQuerySeconds.Since(time.Now(), "postponeReminder")
+	return this.db.postponeReminder(reminderId, delay, maxFailures)
+}
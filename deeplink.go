@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+)
+
+// bytes of the signature kept in the token, enough against guessing,
+// while the token fits into 64 characters of telegram start parameter
+const linkSignatureLength int = 9
+
+// signs start link tokens, so ids of checks can not be enumerated with forged links
+type linkSigner struct {
+	key []byte
+}
+
+// the key is derived from the secret, links stay valid while the secret is not changed
+func newLinkSigner(secret string) linkSigner {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("start links"))
+	return linkSigner{mac.Sum(nil)}
+}
+
+// token is action, check id and signature of them, encoded with url-safe base64
+func (this linkSigner) sign(action int, checkId int64) string {
+	data := binary.AppendUvarint([]byte{byte(action)}, uint64(checkId))
+	data = append(data, this.signature(data)...)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (this linkSigner) verify(token string) (int, int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) <= linkSignatureLength+1 {
+		return 0, 0, newUserError("error.link_invalid")
+	}
+	signed := data[:len(data)-linkSignatureLength]
+	if !hmac.Equal(this.signature(signed), data[len(signed):]) {
+		return 0, 0, newUserError("error.link_invalid")
+	}
+	checkId, n := binary.Uvarint(signed[1:])
+	if n != len(signed)-1 {
+		return 0, 0, newUserError("error.link_invalid")
+	}
+	return int(signed[0]), int64(checkId), nil
+}
+
+func (this linkSigner) signature(data []byte) []byte {
+	mac := hmac.New(sha256.New, this.key)
+	mac.Write(data)
+	return mac.Sum(nil)[:linkSignatureLength]
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestLinkSigner(t *testing.T) {
+	signer := newLinkSigner("secret")
+	// signed data with a byte after the check id, it is not produced by sign
+	trailing := binary.AppendUvarint([]byte{byte(linkOpen)}, 42)
+	trailing = append(trailing, 0)
+	trailing = append(trailing, signer.signature(trailing)...)
+	valid := signer.sign(linkClone, 1<<40)
+	// last characters may carry unused bits, so a character inside the signature is changed
+	tampered := []byte(valid)
+	if tampered[len(tampered)-4] == 'A' {
+		tampered[len(tampered)-4] = 'B'
+	} else {
+		tampered[len(tampered)-4] = 'A'
+	}
+	tests := []struct {
+		name       string
+		token      string
+		wantAction int
+		wantId     int64
+		wantErr    bool
+	}{
+		{name: "round trip", token: valid, wantAction: linkClone, wantId: 1 << 40},
+		{name: "round trip of small id", token: signer.sign(linkOpen, 1), wantAction: linkOpen, wantId: 1},
+		{name: "tampered signature", token: string(tampered), wantErr: true},
+		{name: "other secret", token: newLinkSigner("other").sign(linkClone, 1<<40), wantErr: true},
+		{name: "truncated token", token: valid[:len(valid)-2], wantErr: true},
+		{name: "signature only", token: base64.RawURLEncoding.EncodeToString(make([]byte, linkSignatureLength)), wantErr: true},
+		{name: "trailing bytes", token: base64.RawURLEncoding.EncodeToString(trailing), wantErr: true},
+		{name: "not base64", token: "!!!", wantErr: true},
+		{name: "empty", token: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, checkId, err := signer.verify(tt.token)
+			if tt.wantErr {
+				var uerr userError
+				if !errors.As(err, &uerr) || uerr.key != "error.link_invalid" {
+					t.Fatalf("error = %v, want error.link_invalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if action != tt.wantAction || checkId != tt.wantId {
+				t.Errorf("verify = %d, %d, want %d, %d", action, checkId, tt.wantAction, tt.wantId)
+			}
+		})
+	}
+	if len(valid) > 64 {
+		t.Errorf("token %q does not fit into start parameter", valid)
+	}
+}
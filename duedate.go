@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDueHour int = 18
+	// date as yyyymmdd, used in date picker callbacks
+	dueDateLayout string = "20060102"
+	// relative due dates are bounded, so large amounts do not overflow
+	maxDueAhead time.Duration = 10 * 365 * 24 * time.Hour
+)
+
+var dueUnits = map[string]time.Duration{
+	"minute":  time.Minute,
+	"minutes": time.Minute,
+	"min":     time.Minute,
+	"hour":    time.Hour,
+	"hours":   time.Hour,
+	"h":       time.Hour,
+	"day":     24 * time.Hour,
+	"days":    24 * time.Hour,
+	"d":       24 * time.Hour,
+	"week":    7 * 24 * time.Hour,
+	"weeks":   7 * 24 * time.Hour,
+	"w":       7 * 24 * time.Hour,
+	"минуту":  time.Minute,
+	"минуты":  time.Minute,
+	"минут":   time.Minute,
+	"мин":     time.Minute,
+	"час":     time.Hour,
+	"часа":    time.Hour,
+	"часов":   time.Hour,
+	"ч":       time.Hour,
+	"день":    24 * time.Hour,
+	"дня":     24 * time.Hour,
+	"дней":    24 * time.Hour,
+	"д":       24 * time.Hour,
+	"неделю":  7 * 24 * time.Hour,
+	"недели":  7 * 24 * time.Hour,
+	"недель":  7 * 24 * time.Hour,
+	"нед":     7 * 24 * time.Hour,
+}
+
+var dueWeekdays = map[string]time.Weekday{
+	"sunday":      time.Sunday,
+	"sun":         time.Sunday,
+	"monday":      time.Monday,
+	"mon":         time.Monday,
+	"tuesday":     time.Tuesday,
+	"tue":         time.Tuesday,
+	"wednesday":   time.Wednesday,
+	"wed":         time.Wednesday,
+	"thursday":    time.Thursday,
+	"thu":         time.Thursday,
+	"friday":      time.Friday,
+	"fri":         time.Friday,
+	"saturday":    time.Saturday,
+	"sat":         time.Saturday,
+	"воскресенье": time.Sunday,
+	"вс":          time.Sunday,
+	"понедельник": time.Monday,
+	"пн":          time.Monday,
+	"вторник":     time.Tuesday,
+	"вт":          time.Tuesday,
+	"среда":       time.Wednesday,
+	"среду":       time.Wednesday,
+	"ср":          time.Wednesday,
+	"четверг":     time.Thursday,
+	"чт":          time.Thursday,
+	"пятница":     time.Friday,
+	"пятницу":     time.Friday,
+	"пт":          time.Friday,
+	"суббота":     time.Saturday,
+	"субботу":     time.Saturday,
+	"сб":          time.Saturday,
+}
+
+var dueDayLayouts = []string{
+	"2.1.2006",
+	"2.1",
+	"2006-01-02",
+}
+
+// understands "in 3 days", "in 2 hours", "tomorrow", "friday 18:00",
+// "25.12 10:00", "2024-12-25" and "18:00", russian words too, e.g. "через 3 дня" or "в пятницу 18:00",
+// result is always after now
+func parseDueDate(text string, now time.Time) (time.Time, error) {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) == 0 {
+		return time.Time{}, newUserError("error.due_empty")
+	}
+	var due time.Time
+	var err error
+	if words[0] == "in" || words[0] == "через" {
+		due, err = parseDueDuration(words[1:], now)
+	} else {
+		due, err = parseDueMoment(words, now)
+	}
+	if err != nil {
+		return time.Time{}, newUserError("error.due_not_understood", text)
+	}
+	if !due.After(now) {
+		return time.Time{}, newUserError("error.due_in_past", due)
+	}
+	return due, nil
+}
+
+func parseDueDuration(words []string, now time.Time) (time.Time, error) {
+	// "in 3 days", "in 3days", "in a day" and "через день" are fine
+	if len(words) == 1 {
+		split := strings.IndexFunc(words[0], func(r rune) bool { return r < '0' || r > '9' })
+		if split > 0 {
+			words = []string{words[0][:split], words[0][split:]}
+		}
+	}
+	if len(words) == 1 {
+		words = []string{"a", words[0]}
+	}
+	if len(words) != 2 {
+		return time.Time{}, errors.New("expected amount and unit")
+	}
+	amount := 1
+	if words[0] != "a" && words[0] != "an" {
+		var err error
+		if amount, err = strconv.Atoi(words[0]); err != nil || amount <= 0 {
+			return time.Time{}, fmt.Errorf("invalid amount %q", words[0])
+		}
+	}
+	unit, ok := dueUnits[words[1]]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown unit %q", words[1])
+	}
+	if time.Duration(amount) > maxDueAhead/unit {
+		return time.Time{}, fmt.Errorf("amount %d is too large", amount)
+	}
+	return now.Add(time.Duration(amount) * unit), nil
+}
+
+func parseDueMoment(words []string, now time.Time) (time.Time, error) {
+	var day time.Time
+	dayKnown, dayIsWeekday := false, false
+	hour, minute := defaultDueHour, 0
+	timeKnown := false
+	year, month, date := now.Date()
+	today := time.Date(year, month, date, 0, 0, 0, 0, now.Location())
+	for _, word := range words {
+		if word == "at" || word == "в" {
+			continue
+		}
+		if h, m, ok := parseDueClock(word); ok && !timeKnown {
+			hour, minute, timeKnown = h, m, true
+			continue
+		}
+		if dayKnown {
+			return time.Time{}, fmt.Errorf("unexpected %q", word)
+		}
+		dayKnown = true
+		switch word {
+		case "today", "tonight", "сегодня":
+			day = today
+		case "tomorrow", "завтра":
+			day = today.AddDate(0, 0, 1)
+		default:
+			if weekday, ok := dueWeekdays[word]; ok {
+				day = today.AddDate(0, 0, (int(weekday)-int(today.Weekday())+7)%7)
+				dayIsWeekday = true
+			} else if d, err := parseDueDay(word, today); err == nil {
+				day = d
+			} else {
+				return time.Time{}, err
+			}
+		}
+	}
+	if !dayKnown && !timeKnown {
+		return time.Time{}, errors.New("no day or time found")
+	}
+	if !dayKnown {
+		day = today
+	}
+	due := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location())
+	if !dayKnown && !due.After(now) {
+		due = due.AddDate(0, 0, 1) // "18:00" when it is already evening means tomorrow
+	} else if dayIsWeekday && !due.After(now) {
+		due = due.AddDate(0, 0, 7) // "friday" on friday evening means the next one
+	}
+	return due, nil
+}
+
+func parseDueClock(word string) (int, int, bool) {
+	hourStr, minuteStr, found := strings.Cut(word, ":")
+	if !found {
+		return 0, 0, false
+	}
+	hour, err := strconv.Atoi(hourStr)
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, 0, false
+	}
+	minute, err := strconv.Atoi(minuteStr)
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
+
+func parseDueDay(word string, today time.Time) (time.Time, error) {
+	for _, layout := range dueDayLayouts {
+		if d, err := time.ParseInLocation(layout, word, today.Location()); err == nil {
+			if layout == "2.1" {
+				d = d.AddDate(today.Year(), 0, 0)
+				if d.Before(today) {
+					d = d.AddDate(1, 0, 0)
+				}
+			}
+			return d, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown day %q", word)
+}
+
+// date picked with keyboard, due at default hour of the day
+func parsePickedDueDate(value string, now time.Time) (time.Time, error) {
+	day, err := time.ParseInLocation(dueDateLayout, value, now.Location())
+	if err != nil {
+		return time.Time{}, err
+	}
+	due := time.Date(day.Year(), day.Month(), day.Day(), defaultDueHour, 0, 0, 0, now.Location())
+	if !due.After(now) {
+		return time.Time{}, newUserError("error.picked_due_in_past", due)
+	}
+	return due, nil
+}
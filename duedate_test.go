@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseDueDate(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// wednesday noon
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, moscow)
+	// the day before daylight saving time starts
+	beforeDst := time.Date(2024, 3, 9, 12, 0, 0, 0, newYork)
+	tests := []struct {
+		name    string
+		text    string
+		now     time.Time
+		want    time.Time
+		wantErr string
+	}{
+		{name: "relative days", text: "in 3 days", now: now, want: time.Date(2024, 5, 18, 12, 0, 0, 0, moscow)},
+		{name: "relative glued unit", text: "in 2h", now: now, want: time.Date(2024, 5, 15, 14, 0, 0, 0, moscow)},
+		{name: "relative article", text: "in a week", now: now, want: time.Date(2024, 5, 22, 12, 0, 0, 0, moscow)},
+		{name: "relative russian", text: "через 3 дня", now: now, want: time.Date(2024, 5, 18, 12, 0, 0, 0, moscow)},
+		{name: "relative russian without amount", text: "через час", now: now, want: time.Date(2024, 5, 15, 13, 0, 0, 0, moscow)},
+		{name: "tomorrow at default hour", text: "Tomorrow", now: now, want: time.Date(2024, 5, 16, 18, 0, 0, 0, moscow)},
+		{name: "tomorrow with time in russian", text: "завтра в 10:30", now: now, want: time.Date(2024, 5, 16, 10, 30, 0, 0, moscow)},
+		{name: "weekday ahead", text: "friday 18:00", now: now, want: time.Date(2024, 5, 17, 18, 0, 0, 0, moscow)},
+		{name: "weekday passed today", text: "wednesday 10:00", now: now, want: time.Date(2024, 5, 22, 10, 0, 0, 0, moscow)},
+		{name: "weekday in russian", text: "в пятницу", now: now, want: time.Date(2024, 5, 17, 18, 0, 0, 0, moscow)},
+		{name: "day and month ahead", text: "25.12 10:00", now: now, want: time.Date(2024, 12, 25, 10, 0, 0, 0, moscow)},
+		{name: "day and month passed", text: "1.5", now: now, want: time.Date(2025, 5, 1, 18, 0, 0, 0, moscow)},
+		{name: "iso date", text: "2024-12-25", now: now, want: time.Date(2024, 12, 25, 18, 0, 0, 0, moscow)},
+		{name: "full date", text: "1.1.2025 9:05", now: now, want: time.Date(2025, 1, 1, 9, 5, 0, 0, moscow)},
+		{name: "time later today", text: "18:00", now: now, want: time.Date(2024, 5, 15, 18, 0, 0, 0, moscow)},
+		{name: "time passed today", text: "11:00", now: now, want: time.Date(2024, 5, 16, 11, 0, 0, 0, moscow)},
+		{name: "duration over dst", text: "in 1 day", now: beforeDst, want: time.Date(2024, 3, 10, 13, 0, 0, 0, newYork)},
+		{name: "calendar day over dst", text: "tomorrow 12:00", now: beforeDst, want: time.Date(2024, 3, 10, 12, 0, 0, 0, newYork)},
+		{name: "empty", text: "  ", now: now, wantErr: "error.due_empty"},
+		{name: "gibberish", text: "whenever", now: now, wantErr: "error.due_not_understood"},
+		{name: "negative amount", text: "in -3 days", now: now, wantErr: "error.due_not_understood"},
+		{name: "overflowing amount", text: "in 99999999999 days", now: now, wantErr: "error.due_not_understood"},
+		{name: "amount too far ahead", text: "in 9999 weeks", now: now, wantErr: "error.due_not_understood"},
+		{name: "amount close to bound", text: "in 500 weeks", now: now, want: time.Date(2033, 12, 14, 12, 0, 0, 0, moscow)},
+		{name: "unknown unit", text: "in 3 parsecs", now: now, wantErr: "error.due_not_understood"},
+		{name: "two days", text: "today tomorrow", now: now, wantErr: "error.due_not_understood"},
+		{name: "invalid time", text: "today 25:00", now: now, wantErr: "error.due_not_understood"},
+		{name: "past date", text: "2024-01-01", now: now, wantErr: "error.due_in_past"},
+		{name: "past time today", text: "today 10:00", now: now, wantErr: "error.due_in_past"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDueDate(tt.text, tt.now)
+			if tt.wantErr != "" {
+				var uerr userError
+				if !errors.As(err, &uerr) || uerr.key != tt.wantErr {
+					t.Fatalf("error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) || got.Location() != tt.now.Location() {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,7 +3,11 @@ package main
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type check struct {
@@ -13,7 +17,24 @@ type check struct {
 	Difficulty  int    `sql:"difficulty"`
 	Typ         int    `sql:"type"`
 	Description string `sql:"description"`
-	Attempts    []attempt
+	// party checks belong to the chat they were created in
+	Party bool      `sql:"party"`
+	DueAt time.Time `sql:"due_at"`
+	// recurring checks are instances of series, first instance identifies it
+	Recurrence string `sql:"recurrence"`
+	SeriesId   int64  `sql:"series_id"`
+	Streak     int
+	// the next instance of the series is opened, so the check may not be reopened
+	HasNext bool
+	// thought cabinet modifier of the skill for the viewer
+	Modifier int
+	// the viewer may undo, change or annotate the last attempt
+	CanUndo   bool
+	CanChange bool
+	CanNote   bool
+	// the viewer may save the check as a template
+	CanSave  bool
+	Attempts []attempt
 	// metadata attributes
 	CreatedByUser    int64     `sql:"created_by_user"`
 	CreatedByChat    int64     `sql:"created_by_chat"`
@@ -25,12 +46,23 @@ func (this check) empty() bool {
 	return this.Skill+this.Difficulty+this.Typ == 0
 }
 
+func (this check) lastAttempt() (attempt, bool) {
+	if len(this.Attempts) == 0 {
+		return attempt{}, false
+	}
+	return this.Attempts[len(this.Attempts)-1], true
+}
+
+// closed check with the result other than canceled advances research of thoughts
+func (this check) completed() bool {
+	last, _ := this.lastAttempt()
+	return this.closed() && last.Result != resCanceled && last.Result != resMissed
+}
+
 func (this check) closed() bool {
 	if i := len(this.Attempts); i > 0 {
 		switch this.Attempts[len(this.Attempts)-1].Result {
-		case resCanceled:
-			fallthrough
-		case resSuccess:
+		case resCanceled, resSuccess, resMissed:
 			return true
 		case resFailure:
 			return this.Typ != typRetriable
@@ -54,6 +86,10 @@ func (this check) validate() error {
 		this.CreatedByMessage == 0 {
 		return errors.New("incomplete metadata")
 	}
+	// private chat of the user has the id of the user
+	if this.Party && this.CreatedByChat == this.CreatedByUser {
+		return newUserError("error.party_private_chat")
+	}
 	return nil
 }
 
@@ -62,19 +98,364 @@ type attempt struct {
 	Id      int64 `sql:"attempt_id"`
 	CheckId int64 `sql:"check_id"`
 	Result  int   `sql:"result"`
+	// what happened, optional text and photo as telegram file id
+	Note    string `sql:"note"`
+	PhotoId string `sql:"photo_file_id"`
 	//metadata attributes
+	CreatedByUser    int64     `sql:"a_created_by_user"`
 	CreatedByChat    int64     `sql:"created_by_chat"`
 	CreatedByMessage int       `sql:"created_by_message"`
 	CreatedAt        time.Time `sql:"a_created_at"`
 }
 
+// results made by users, missed one is made only by the bot, see missInstance
 func (this attempt) validate() error {
 	if this.Result < resCanceled || this.Result > resSuccess {
 		return fmt.Errorf("invalid result %d", this.Result)
 	}
-	if this.CreatedByChat == 0 ||
+	if this.CreatedByUser == 0 ||
+		this.CreatedByChat == 0 ||
 		this.CreatedByMessage == 0 {
 		return errors.New("incomplete metadata")
 	}
 	return nil
 }
+
+// personal checks may be attempted only by their owner,
+// party checks by anyone in the chat they belong to
+func (this check) attemptableBy(userId int64, chatId int64) error {
+	if this.closed() {
+		return newUserError("error.check_closed", this.Id)
+	}
+	if this.Party {
+		if this.CreatedByChat != chatId {
+			return newUserError("error.check_other_chat", this.Id)
+		}
+	} else if this.CreatedByUser != userId {
+		return newUserError("error.check_other_user", this.Id)
+	}
+	return nil
+}
+
+type reminder struct {
+	Id       int64     `sql:"reminder_id"`
+	CheckId  int64     `sql:"check_id"`
+	Kind     int       `sql:"kind"`
+	RemindAt time.Time `sql:"remind_at"`
+}
+
+// reminders to be sent for check with due date, ones in the past are skipped
+func (this check) reminders(now time.Time) []reminder {
+	var result []reminder
+	if this.DueAt.IsZero() {
+		return nil
+	}
+	if before := this.DueAt.Add(-reminderLead); before.After(now) {
+		result = append(result, reminder{CheckId: this.Id, Kind: remBeforeDue, RemindAt: before})
+	}
+	result = append(result, reminder{CheckId: this.Id, Kind: remOverdue, RemindAt: this.DueAt})
+	return result
+}
+
+func (this check) rule() recurrence {
+	rule, _ := parseRecurrence(this.Recurrence)
+	return rule
+}
+
+// due date of the open instance or of the one to be opened after this
+func (this check) nextDue(now time.Time) time.Time {
+	rule := this.rule()
+	if rule.empty() || !this.closed() {
+		return this.DueAt
+	}
+	if this.DueAt.IsZero() {
+		return rule.first(now)
+	}
+	// the same time of the day in the zone of now, even if daylight saving time changes
+	return rule.next(this.DueAt.In(now.Location()), now)
+}
+
+// number of consecutive periods with successfully closed instances, instances are newest first,
+// the open instance does not break the streak until it is overdue,
+// success after the due date or a period without instance, e.g. while the bot was down, breaks it
+func seriesStreak(instances []check, now time.Time) int {
+	streak := 0
+	for i, chk := range instances {
+		if !chk.closed() {
+			if i == 0 && (chk.DueAt.IsZero() || chk.DueAt.After(now)) {
+				continue
+			}
+			break
+		}
+		last, _ := chk.lastAttempt()
+		if last.Result != resSuccess || !chk.DueAt.IsZero() && last.CreatedAt.After(chk.DueAt) {
+			break
+		}
+		if i > 0 && !chk.DueAt.IsZero() && !instances[i-1].DueAt.IsZero() {
+			// the newer instance is expected at the very next occurrence, daylight saving time
+			// moves it by an hour at most, while a skipped period moves it by a day at least
+			expected := chk.rule().next(chk.DueAt, chk.DueAt)
+			if instances[i-1].DueAt.Sub(expected) > 12*time.Hour {
+				break
+			}
+		}
+		streak++
+	}
+	return streak
+}
+
+type user struct {
+	Id           int64     `sql:"user_id"`
+	UserName     string    `sql:"user_name"`
+	FirstName    string    `sql:"first_name"`
+	LanguageCode string    `sql:"language_code"` // from telegram settings
+	Language     string    `sql:"language"`      // chosen with /language, empty for automatic choice
+	Timezone     string    `sql:"timezone"`      // IANA name, empty for server time zone
+	DateFormat   string    `sql:"date_format"`   // one of dateFormats, empty for default
+	UpdatedAt    time.Time `sql:"updated_at"`
+
+	// loaded Timezone of cached users, see cacheZone
+	zone *time.Location
+}
+
+// texts and dates as the user chose them
+func (this user) locale() locale {
+	zone := this.zone
+	if zone == nil {
+		zone = loadZone(this.Timezone)
+	}
+	return newLocale(this.language()).withDates(zone, this.DateFormat)
+}
+
+// loads time zone once, when the user is cached or the zone is changed, instead of on every update
+func (this *user) cacheZone() {
+	this.zone = loadZone(this.Timezone)
+}
+
+// language tag of user's texts, chosen one takes precedence over telegram settings
+func (this user) language() string {
+	if this.Language != "" {
+		return this.Language
+	}
+	return this.LanguageCode
+}
+
+// username if user has one, first name otherwise
+func (this user) displayName() string {
+	if this.UserName != "" {
+		return "@" + this.UserName
+	} else if this.FirstName != "" {
+		return this.FirstName
+	}
+	return fmt.Sprintf("user %d", this.Id)
+}
+
+// preset of a check, which is created with a single tap,
+// party templates belong to the chat they were saved in and are shared with its members
+type template struct {
+	Id         int64  `sql:"template_id"`
+	Name       string `sql:"name"`
+	Skill      int    `sql:"skill"`
+	Difficulty int    `sql:"difficulty"`
+	Typ        int    `sql:"type"`
+	// may have placeholders in braces, e.g. Run {distance} km, which are asked on use
+	Description   string    `sql:"description"`
+	Party         bool      `sql:"party"`
+	CreatedByUser int64     `sql:"created_by_user"`
+	CreatedByChat int64     `sql:"created_by_chat"`
+	CreatedAt     time.Time `sql:"created_at"`
+}
+
+var placeholderPattern = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// names of placeholders in order of appearance, repeated ones are asked once
+func (this template) placeholders() []string {
+	var names []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(this.Description, -1) {
+		if name := strings.TrimSpace(match[1]); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// check of the template, values are given in order of placeholders,
+// metadata is to be filled by the caller
+func (this template) check(values []string) check {
+	descr := placeholderPattern.ReplaceAllStringFunc(this.Description, func(match string) string {
+		if i := slices.Index(this.placeholders(), strings.TrimSpace(match[1:len(match)-1])); i >= 0 && i < len(values) {
+			return values[i]
+		}
+		return match
+	})
+	return check{
+		Skill:       this.Skill,
+		Difficulty:  this.Difficulty,
+		Typ:         this.Typ,
+		Description: descr,
+		Party:       this.Party,
+	}
+}
+
+// properties of the check are validated, when it is created
+func (this template) validate() error {
+	name := strings.TrimSpace(this.Name)
+	if name == "" {
+		return newUserError("error.template_name_empty")
+	}
+	if utf8.RuneCountInString(name) > maxTemplateNameLength {
+		return newUserError("error.template_name_too_long", maxTemplateNameLength)
+	}
+	if utf8.RuneCountInString(this.Description) > maxDescriptionLength {
+		return newUserError("error.description_too_long", maxDescriptionLength)
+	}
+	if this.CreatedByUser == 0 || this.CreatedByChat == 0 {
+		return errors.New("incomplete metadata")
+	}
+	return nil
+}
+
+// personal templates may be used only by their author, party templates by anyone in their chat
+func (this template) usableBy(userId int64, chatId int64) error {
+	if this.Party {
+		if this.CreatedByChat != chatId {
+			return newUserError("error.template_other_chat", this.Name)
+		}
+	} else if this.CreatedByUser != userId {
+		return newUserError("error.template_other_user", this.Name)
+	}
+	return nil
+}
+
+// chat where the bot was used, e.g. for announcements of operators
+type chat struct {
+	Id        int64     `sql:"chat_id"`
+	Type      string    `sql:"type"`
+	Title     string    `sql:"title"`
+	UpdatedAt time.Time `sql:"updated_at"`
+}
+
+// totals of the bot for operators
+type botStats struct {
+	Users    int `sql:"users"`
+	Chats    int `sql:"chats"`
+	Checks   int `sql:"checks"`
+	Attempts int `sql:"attempts"`
+}
+
+// user whose updates are skipped until the time
+type block struct {
+	UserId       int64     `sql:"user_id"`
+	BlockedUntil time.Time `sql:"blocked_until"`
+	Times        int       `sql:"times"` // how many times the user was blocked
+	Reason       string    `sql:"reason"`
+}
+
+// blocks the user for duration from now, every block following the previous one is twice as long,
+// up to maxDuration, unless the previous one ended more than forgetAfter ago
+func (this *block) extend(previous block, now time.Time, duration time.Duration, maxDuration time.Duration, forgetAfter time.Duration) {
+	this.Times = 1
+	if previous.Times > 0 && !previous.BlockedUntil.Before(now.Add(-forgetAfter)) {
+		this.Times = previous.Times + 1
+	}
+	// doubling stops at maxDuration, so it does not overflow
+	for i := 1; i < this.Times && duration < maxDuration; i++ {
+		duration *= 2
+	}
+	this.BlockedUntil = now.Add(min(duration, maxDuration))
+}
+
+// aggregated results of a chat member
+type leaderboardRow struct {
+	UserId            int64  `sql:"user_id"`
+	UserName          string `sql:"user_name"`
+	FirstName         string `sql:"first_name"`
+	Successes         int    `sql:"successes"`
+	WeightedSuccesses int    `sql:"weighted_successes"`
+	WeightedAttempts  int    `sql:"weighted_attempts"`
+	Hardest           int    `sql:"hardest"`
+}
+
+// share of passed difficulty among all non canceled attempts,
+// so passing a Heroic check counts more than passing a Trivial one
+func (this leaderboardRow) weightedRate() float64 {
+	if this.WeightedAttempts == 0 {
+		return 0
+	}
+	return float64(this.WeightedSuccesses) / float64(this.WeightedAttempts)
+}
+
+func (this leaderboardRow) user() user {
+	return user{Id: this.UserId, UserName: this.UserName, FirstName: this.FirstName}
+}
+
+type skillModifier struct {
+	Skill int
+	Value int
+}
+
+// thought of the cabinet, researched either for days or for completed checks
+// name and problem of the thought are in i18n catalogs
+type thoughtDef struct {
+	ResearchDays   int
+	ResearchChecks int
+	Researching    []skillModifier // applied while researching
+	Internalized   []skillModifier // applied after research is finished
+}
+
+type cabinetThought struct {
+	Id         int64     `sql:"cabinet_id"`
+	UserId     int64     `sql:"user_id"`
+	Thought    int       `sql:"thought"`
+	Slot       int       `sql:"slot"`
+	Progress   int       `sql:"progress"`
+	StartedAt  time.Time `sql:"started_at"`
+	FinishedAt time.Time `sql:"finished_at"`
+}
+
+func (this cabinetThought) def() thoughtDef {
+	return thoughtDefs[this.Thought]
+}
+
+func (this cabinetThought) researching() bool {
+	return this.FinishedAt.IsZero()
+}
+
+func (this cabinetThought) researched(now time.Time) bool {
+	def := this.def()
+	if def.ResearchChecks > 0 {
+		return this.Progress >= def.ResearchChecks
+	}
+	return !now.Before(this.StartedAt.AddDate(0, 0, def.ResearchDays))
+}
+
+func (this cabinetThought) modifiers() []skillModifier {
+	if this.researching() {
+		return this.def().Researching
+	}
+	return this.def().Internalized
+}
+
+func (this cabinetThought) validate() error {
+	if this.Thought < thoughtVolumetric || this.Thought > thoughtSelfCritique {
+		return fmt.Errorf("invalid thought %d", this.Thought)
+	}
+	if this.Slot < 1 || this.Slot > maxCabinetSlots {
+		return fmt.Errorf("invalid slot %d", this.Slot)
+	}
+	if this.UserId == 0 {
+		return errors.New("incomplete metadata")
+	}
+	return nil
+}
+
+// sums modifiers of all thoughts in the cabinet per skill, indexed by skill identifier
+func cabinetModifiers(cabinet []cabinetThought) [len(skillNames)]int {
+	var result [len(skillNames)]int
+	for _, ct := range cabinet {
+		for _, mod := range ct.modifiers() {
+			result[mod.Skill] += mod.Value
+		}
+	}
+	return result
+}
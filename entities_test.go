@@ -0,0 +1,173 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckValidate(t *testing.T) {
+	valid := check{Typ: typRetriable, Skill: intLogic, Difficulty: difEasy, CreatedByUser: 1, CreatedByChat: 1, CreatedByMessage: 1}
+	tests := []struct {
+		name    string
+		modify  func(chk *check)
+		wantErr bool
+	}{
+		{name: "personal check in private chat", modify: func(chk *check) {}},
+		{name: "party check in group", modify: func(chk *check) { chk.Party = true; chk.CreatedByChat = -100 }},
+		{name: "party check in private chat", modify: func(chk *check) { chk.Party = true }, wantErr: true},
+		{name: "invalid skill", modify: func(chk *check) { chk.Skill = motComposure + 1 }, wantErr: true},
+		{name: "missing message", modify: func(chk *check) { chk.CreatedByMessage = 0 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chk := valid
+			tt.modify(&chk)
+			if err := chk.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSeriesStreak(t *testing.T) {
+	zone := time.FixedZone("MSK", 3*60*60)
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, zone)
+	// instance of daily series due days ago, closed with the result hours before its due date
+	instance := func(daysAgo int, result int, hoursBeforeDue int) check {
+		due := time.Date(2024, 5, 15-daysAgo, 18, 0, 0, 0, zone)
+		chk := check{Typ: typNonRetriable, Recurrence: "daily", DueAt: due}
+		if result != resDefault {
+			chk.Attempts = []attempt{{Result: result, CreatedAt: due.Add(-time.Duration(hoursBeforeDue) * time.Hour)}}
+		}
+		return chk
+	}
+	tests := []struct {
+		name      string
+		instances []check
+		want      int
+	}{
+		{name: "no instances", want: 0},
+		{name: "open first instance", instances: []check{instance(0, resDefault, 0)}, want: 0},
+		{
+			name:      "open instance does not break",
+			instances: []check{instance(0, resDefault, 0), instance(1, resSuccess, 1), instance(2, resSuccess, 1)},
+			want:      2,
+		},
+		{
+			name:      "failure breaks",
+			instances: []check{instance(1, resSuccess, 1), instance(2, resFailure, 1), instance(3, resSuccess, 1)},
+			want:      1,
+		},
+		{
+			name:      "missed period breaks",
+			instances: []check{instance(0, resDefault, 0), instance(1, resMissed, 0), instance(2, resSuccess, 1)},
+			want:      0,
+		},
+		{
+			name:      "overdue open instance breaks",
+			instances: []check{instance(1, resDefault, 0), instance(2, resSuccess, 1)},
+			want:      0,
+		},
+		{
+			name:      "success after due date breaks",
+			instances: []check{instance(1, resSuccess, -2), instance(2, resSuccess, 1)},
+			want:      0,
+		},
+		{
+			name:      "period without instance breaks",
+			instances: []check{instance(0, resDefault, 0), instance(1, resSuccess, 1), instance(4, resSuccess, 1)},
+			want:      1,
+		},
+		{
+			name:      "open instance after gap keeps nothing before it",
+			instances: []check{instance(0, resDefault, 0), instance(3, resSuccess, 1)},
+			want:      0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := seriesStreak(tt.instances, now); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttemptValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  int
+		wantErr bool
+	}{
+		{name: "canceled", result: resCanceled},
+		{name: "failure", result: resFailure},
+		{name: "success", result: resSuccess},
+		{name: "no result", result: resDefault, wantErr: true},
+		{name: "missed is made by the bot only", result: resMissed, wantErr: true},
+		{name: "unknown", result: resMissed + 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			att := attempt{Result: tt.result, CreatedByUser: 1, CreatedByChat: 1, CreatedByMessage: 1}
+			if err := att.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUserLocale(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database is not available")
+	}
+	tests := []struct {
+		name string
+		usr  user
+		want *time.Location
+	}{
+		{name: "server zone", usr: user{}, want: time.Local},
+		{name: "unknown zone", usr: user{Timezone: "Mars/Olympus"}, want: time.Local},
+		{name: "zone read from db", usr: user{Timezone: "America/New_York"}, want: newYork},
+		{name: "cached zone", usr: user{Timezone: "America/New_York", zone: time.UTC}, want: time.UTC},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.usr.locale().zone; got.String() != tt.want.String() {
+				t.Errorf("zone = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	usr := user{Timezone: "America/New_York"}
+	usr.cacheZone()
+	usr.Timezone = ""
+	if got := usr.locale().zone; got.String() != newYork.String() {
+		t.Errorf("cached zone = %v, want %v", got, newYork)
+	}
+}
+
+func TestBlockExtend(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		previous  block
+		wantTimes int
+		want      time.Duration
+	}{
+		{name: "first block", wantTimes: 1, want: time.Hour},
+		{name: "active block", previous: block{BlockedUntil: now.Add(time.Hour), Times: 1}, wantTimes: 2, want: 2 * time.Hour},
+		{name: "recent block", previous: block{BlockedUntil: now.Add(-time.Hour), Times: 3}, wantTimes: 4, want: 8 * time.Hour},
+		{name: "longest block", previous: block{BlockedUntil: now, Times: 5}, wantTimes: 6, want: 24 * time.Hour},
+		{name: "many blocks", previous: block{BlockedUntil: now, Times: 1000}, wantTimes: 1001, want: 24 * time.Hour},
+		{name: "forgotten block", previous: block{BlockedUntil: now.Add(-49 * time.Hour), Times: 5}, wantTimes: 1, want: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blk := block{UserId: 1}
+			blk.extend(tt.previous, now, time.Hour, 24*time.Hour, 48*time.Hour)
+			if blk.Times != tt.wantTimes || !blk.BlockedUntil.Equal(now.Add(tt.want)) {
+				t.Errorf("block = %d times until %v, want %d times for %v", blk.Times, blk.BlockedUntil, tt.wantTimes, tt.want)
+			}
+		})
+	}
+}
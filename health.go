@@ -0,0 +1,52 @@
+package main
+
+import (
+	"discocheckbot/api"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// liveness fails when the loop of updates makes no progress, e.g. a handler or a request hangs,
+// so the bot is to be restarted
+func (this *DiscoCheckBot) handleLiveness(bot *api.Bot) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state := bot.PollState()
+		if silence := time.Since(state.LastActivity); silence > state.Timeout+state.RetryDelay+healthGrace {
+			writeHealth(w, fmt.Errorf("updates loop is stuck for %v", silence.Round(time.Second)))
+			return
+		}
+		writeHealth(w, nil)
+	}
+}
+
+// readiness fails while telegram or database is not available, restart would not help then
+func (this *DiscoCheckBot) handleReadiness(bot *api.Bot) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state := bot.PollState()
+		switch {
+		case state.LastError != nil:
+			writeHealth(w, fmt.Errorf("requesting updates failed: %w", state.LastError))
+		case state.LastSuccess.IsZero():
+			writeHealth(w, fmt.Errorf("updates are not requested yet"))
+		case time.Since(state.LastSuccess) > state.Timeout+state.RetryDelay+healthGrace:
+			writeHealth(w, fmt.Errorf("updates are not received since %v", state.LastSuccess.Format(time.RFC3339)))
+		default:
+			if err := this.db.ping(); err != nil {
+				writeHealth(w, fmt.Errorf("database is not available: %w", err))
+				return
+			}
+			writeHealth(w, nil)
+		}
+	}
+}
+
+func writeHealth(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,179 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// language used for missing translations and unknown languages
+const Fallback string = "en"
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// catalogs shipped with the bot, loaded once, broken catalog is a programming error
+var Default *Bundle = mustLoad()
+
+// text of a key, either simple or plural, plural one has text per form, e.g. "one", "few", "other"
+type message struct {
+	text  string
+	forms map[string]string
+}
+
+func (this *message) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &this.text); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &this.forms); err != nil {
+		return fmt.Errorf("neither text nor plural forms: %w", err)
+	}
+	if _, ok := this.forms[formOther]; !ok {
+		return fmt.Errorf("plural form %q is missing", formOther)
+	}
+	return nil
+}
+
+// catalogs of all languages, keyed by language code, e.g. "en" or "ru"
+type Bundle struct {
+	localizers map[string]*Localizer
+	languages  []string
+}
+
+// reads catalogs named by language, e.g. "ru.json", catalog of the fallback language is required
+func NewBundle(fsys fs.FS, dir string) (*Bundle, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	bundle := Bundle{localizers: make(map[string]*Localizer)}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var messages map[string]message
+		if err = json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("catalog %s is broken: %w", file, err)
+		}
+		lang := strings.TrimSuffix(path.Base(file), ".json")
+		bundle.localizers[lang] = &Localizer{
+			lang:     lang,
+			messages: messages,
+			plural:   pluralRule(lang),
+		}
+		bundle.languages = append(bundle.languages, lang)
+	}
+	fallback, ok := bundle.localizers[Fallback]
+	if !ok {
+		return nil, fmt.Errorf("catalog of fallback language %s is missing", Fallback)
+	}
+	for lang, localizer := range bundle.localizers {
+		if lang != Fallback {
+			localizer.fallback = fallback
+		}
+	}
+	slices.Sort(bundle.languages)
+	return &bundle, nil
+}
+
+func mustLoad() *Bundle {
+	bundle, err := NewBundle(locales, "locales")
+	if err != nil {
+		panic(err)
+	}
+	return bundle
+}
+
+// codes of available languages, sorted
+func (this *Bundle) Languages() []string {
+	return slices.Clone(this.languages)
+}
+
+// available language for IETF tag sent by telegram, e.g. "ru-RU" gives "ru",
+// unknown and empty tags give the fallback language
+func (this *Bundle) Match(tag string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+	if _, ok := this.localizers[lang]; ok {
+		return lang
+	}
+	return Fallback
+}
+
+func (this *Bundle) Localizer(tag string) *Localizer {
+	return this.localizers[this.Match(tag)]
+}
+
+// texts of one language, missing ones are taken from the fallback language
+type Localizer struct {
+	lang     string
+	messages map[string]message
+	plural   func(n int) string
+	fallback *Localizer
+}
+
+func (this *Localizer) Language() string {
+	return this.lang
+}
+
+// text of the key formatted by fmt rules, unknown key is returned as is
+func (this *Localizer) T(key string, args ...any) string {
+	msg, ok := this.messages[key]
+	if !ok || msg.forms != nil {
+		if this.fallback != nil {
+			return this.fallback.T(key, args...)
+		}
+		return key
+	}
+	if len(args) == 0 {
+		return msg.text
+	}
+	return fmt.Sprintf(msg.text, args...)
+}
+
+// plural text of the key for n, n is the first argument of formatting,
+// unless the form has no verb for it, e.g. "every day" for one
+func (this *Localizer) N(key string, n int, args ...any) string {
+	msg, ok := this.messages[key]
+	if !ok || msg.forms == nil {
+		if this.fallback != nil {
+			return this.fallback.N(key, n, args...)
+		}
+		return key
+	}
+	text, ok := msg.forms[this.plural(n)]
+	if !ok {
+		text = msg.forms[formOther]
+	}
+	if len(formatVerbs(text)) > len(args) {
+		args = append([]any{n}, args...)
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// verbs of the format in order, e.g. "%d", "%s" and "%q" of "%d of %s: %q", "%%" is not a verb
+func formatVerbs(format string) []string {
+	var verbs []string
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		// flags, width, precision and argument index come before the verb
+		j := i + 1
+		for j < len(format) && strings.ContainsRune("+-# 0123456789.*[]", rune(format[j])) {
+			j++
+		}
+		if j < len(format) && format[j] != '%' {
+			verbs = append(verbs, "%"+string(format[j]))
+		}
+		i = j
+	}
+	return verbs
+}
@@ -0,0 +1,85 @@
+package i18n
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCatalogVerbs(t *testing.T) {
+	fallback := Default.localizers[Fallback]
+	for _, lang := range Default.Languages() {
+		if lang == Fallback {
+			continue
+		}
+		localizer := Default.localizers[lang]
+		for key, msg := range localizer.messages {
+			want, ok := fallback.messages[key]
+			if !ok {
+				t.Errorf("%s: key %s is missing in %s", lang, key, Fallback)
+				continue
+			}
+			if (msg.forms == nil) != (want.forms == nil) {
+				t.Errorf("%s: key %s is plural in one catalog only", lang, key)
+				continue
+			}
+			if msg.forms == nil {
+				if got, want := formatVerbs(msg.text), formatVerbs(want.text); !slices.Equal(got, want) {
+					t.Errorf("%s: key %s has verbs %v, want %v", lang, key, got, want)
+				}
+				continue
+			}
+			wantVerbs := formatVerbs(want.forms[formOther])
+			for form, text := range msg.forms {
+				// a form may leave out the count, e.g. "every day"
+				got := formatVerbs(text)
+				if !slices.Equal(got, wantVerbs) && !slices.Equal(got, wantVerbs[min(1, len(wantVerbs)):]) {
+					t.Errorf("%s: form %s of key %s has verbs %v, want %v", lang, form, key, got, wantVerbs)
+				}
+			}
+		}
+		for key := range fallback.messages {
+			if _, ok := localizer.messages[key]; !ok {
+				t.Errorf("%s: key %s is missing", lang, key)
+			}
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		lang string
+		n    int
+		want string
+	}{
+		{lang: "en", n: 1, want: "every day"},
+		{lang: "en", n: 3, want: "every 3 days"},
+		{lang: "ru", n: 1, want: "каждый 1 день"},
+		{lang: "ru", n: 3, want: "каждые 3 дня"},
+		{lang: "ru", n: 5, want: "каждые 5 дней"},
+		{lang: "ru", n: 21, want: "каждый 21 день"},
+	}
+	for _, tt := range tests {
+		got := Default.Localizer(tt.lang).N("recurrence.every", tt.n)
+		if got != tt.want || strings.Contains(got, "%!") {
+			t.Errorf("%s %d: got %q, want %q", tt.lang, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatVerbs(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{format: "plain text", want: nil},
+		{format: "%d of %s: %q", want: []string{"%d", "%s", "%q"}},
+		{format: "100%% done in %.1f s", want: []string{"%f"}},
+		{format: "%-10s|%+d", want: []string{"%s", "%d"}},
+	}
+	for _, tt := range tests {
+		if got := formatVerbs(tt.format); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package i18n
+
+// plural forms, named as in CLDR
+const (
+	formOne   string = "one"
+	formFew   string = "few"
+	formMany  string = "many"
+	formOther string = "other"
+)
+
+// CLDR cardinal rules for integers, languages without a rule use the "other" form only
+func pluralRule(lang string) func(n int) string {
+	switch lang {
+	case "en":
+		return pluralEnglish
+	case "ru":
+		return pluralRussian
+	}
+	return func(int) string { return formOther }
+}
+
+func pluralEnglish(n int) string {
+	if n == 1 {
+		return formOne
+	}
+	return formOther
+}
+
+// 1, 21, 101 - one; 2-4, 22-24 - few; 0, 5-20, 25-30 - many
+func pluralRussian(n int) string {
+	if n < 0 {
+		n = -n
+	}
+	switch mod10, mod100 := n%10, n%100; {
+	case mod10 == 1 && mod100 != 11:
+		return formOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return formFew
+	}
+	return formMany
+}
@@ -3,85 +3,204 @@ package main
 import (
 	"discocheckbot/api"
 	"discocheckbot/config"
+	"discocheckbot/i18n"
+	"discocheckbot/logging"
 	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type dbAdapter interface {
 	createCheck(chk *check) error
 	createAttempt(att *attempt) error
+	readAttempt(attemptId int64) (attempt, error)
+	deleteAttempt(attemptId int64, userId int64) error
+	changeAttemptResult(attemptId int64, result int, userId int64) error
+	setAttemptNote(attemptId int64, note string, photoId string) error
+	importChecks(list []check) error
 	init() error
 	listUserChecks(userId int64, offsetId int64, desc bool) ([]check, error)
+	listChatChecks(chatId int64, offsetId int64, desc bool) ([]check, error)
+	searchOpenChecks(userId int64, text string, limit int) ([]check, error)
+	readChatLeaderboard(chatId int64, periodDays int) ([]leaderboardRow, error)
+	saveUser(usr *user) error
+	readUser(userId int64) (user, error)
+	setUserLanguage(userId int64, language string) error
+	setUserTimezone(userId int64, timezone string) error
+	setUserDateFormat(userId int64, dateFormat string) error
+	createReminder(rem *reminder) error
+	listPendingReminders(limit int) ([]reminder, error)
+	markReminderSent(reminderId int64) error
+	postponeReminder(reminderId int64, delay time.Duration, maxFailures int) error
+	cancelReminders(checkId int64, kind int) error
+	listSeriesInstances(seriesId int64) ([]check, error)
+	readCabinet(userId int64) ([]cabinetThought, error)
+	createCabinetThought(ct *cabinetThought) error
+	finishCabinetThought(ct *cabinetThought) error
+	forgetCabinetThought(userId int64, slot int) error
+	advanceResearch(userId int64) error
+	retreatResearch(userId int64) error
 	readCheck(checkId int64) (check, error)
+	blockUser(blk *block, now time.Time, duration time.Duration, maxDuration time.Duration, forgetAfter time.Duration) error
+	listActiveBlocks() ([]block, error)
+	unblockUser(userId int64) error
+	createTemplate(tmpl *template) error
+	readTemplate(templateId int64) (template, error)
+	listUserTemplates(userId int64) ([]template, error)
+	listChatTemplates(chatId int64) ([]template, error)
+	deleteTemplate(templateId int64, userId int64) error
+	saveChat(cht *chat) error
+	listChatIds() ([]int64, error)
+	readStats() (botStats, error)
+	ping() error
+}
+
+// user talking to the bot in a chat, prompts sent to a group are answered in the group
+type dialog struct {
+	userId int64
+	chatId int64
+}
+
+func dialogOf(msg *api.Message) dialog {
+	return dialog{msg.Sender.ID, msg.Chat.ID}
+}
+
+// callbacks of buttons are answered in the chat of their message
+func dialogOfCallback(cbq *api.CallbackQuery) dialog {
+	return dialog{cbq.Sender.ID, cbq.Message.Chat.ID}
+}
+
+// check being created step by step, from a command to its due date
+type checkDraft struct {
+	chk     check
+	askedAt time.Time
+}
+
+// attempt awaiting a note, see requestAttemptNote
+type noteRequest struct {
+	attemptId int64
+	askedAt   time.Time
 }
 
 type DiscoCheckBot struct {
-	checkBuffer map[int64]check
-	db          dbAdapter
+	checkBuffer  map[dialog]checkDraft
+	importBuffer map[int64][]check
+	knownUsers   map[int64]user
+	knownChats   map[int64]chat
+	// announcements of operators awaiting confirmation
+	broadcastBuffer map[int64]string
+	// users who were asked to share location for time zone, by time of the request
+	locationRequests map[int64]time.Time
+	// users who were asked for a note to their attempt
+	noteRequests map[dialog]noteRequest
+	// templates being saved or filled with values of placeholders
+	templateBuffer map[dialog]templateDraft
+	voices         *voiceBook
+	links          linkSigner
+	commands       []botCommand
+	spam           *antiSpam
+	admins         []int64
+	// last attempt may be undone during this time
+	undoWindow time.Duration
+	startedAt  time.Time
+	db         dbAdapter
 }
 
-func NewDiscoCheckBot(cfg *config.ConfigReader) (*DiscoCheckBot, error) {
-	var dbHost, dbName, dbUser, dbPassword string
-	var dbPort float64
-	var err error
-	if err = cfg.GetParameter("db_host", &dbHost); err != nil {
-		return nil, err
-	}
-	if err = cfg.GetParameter("db_port", &dbPort); err != nil {
-		return nil, err
-	}
-	if err = cfg.GetParameter("db_user", &dbUser); err != nil {
-		return nil, err
-	}
-	if err = cfg.GetParameter("db_password", &dbPassword); err != nil {
+func NewDiscoCheckBot(cfg *config.Config) (*DiscoCheckBot, error) {
+	db, err := newPsqlAdapter(cfg.DbHost, cfg.DbUser, cfg.DbPassword, cfg.DbName, cfg.DbPort)
+	if err != nil {
 		return nil, err
 	}
-	if err = cfg.GetParameter("db_name", &dbName); err != nil {
+	if err = db.init(); err != nil {
 		return nil, err
 	}
-	db, err := newPsqlAdapter(dbHost, dbUser, dbPassword, dbName, int(dbPort))
+	voices, err := newVoiceBook(voicesData, "voices", time.Now().UnixNano())
 	if err != nil {
 		return nil, err
 	}
-	if err = db.init(); err != nil {
-		return nil, err
-	}
 	dcb := DiscoCheckBot{
-		make(map[int64]check),
-		db,
+		make(map[dialog]checkDraft),
+		make(map[int64][]check),
+		make(map[int64]user),
+		make(map[int64]chat),
+		make(map[int64]string),
+		make(map[int64]time.Time),
+		make(map[dialog]noteRequest),
+		make(map[dialog]templateDraft),
+		voices,
+		// start links are signed with the token, so they are broken only when the token is revoked
+		newLinkSigner(cfg.BotToken),
+		nil,
+		nil,
+		cfg.AdminIds,
+		time.Second * time.Duration(cfg.UndoWindow),
+		time.Now(),
+		meteredDb{db},
 	}
+	dcb.commands = dcb.newCommandRegistry()
 	return &dcb, nil
 }
 
+// ApplyConfig takes limits of updates, other parameters are used only at start
+func (this *DiscoCheckBot) ApplyConfig(prev *config.Config, next *config.Config) {
+	if this.spam != nil {
+		this.spam.setLimits(next)
+	}
+}
+
 func (this *DiscoCheckBot) OnMessage(bot *api.Bot, msg *api.Message) error {
+	this.rememberUser(msg.Sender)
+	this.rememberChat(msg.Chat)
+	lc := this.locale(msg.Sender)
+	this.forgetStalePrompts(time.Now())
 	command, err := api.ParseCommand(*msg)
 	if command == "" {
+		if _, ok := this.locationRequests[msg.Sender.ID]; ok {
+			return this.handleLocation(bot, msg)
+		}
+		if _, ok := this.noteRequests[dialogOf(msg)]; ok {
+			return this.handleAttemptNote(bot, msg)
+		}
+		if _, ok := this.templateBuffer[dialogOf(msg)]; ok {
+			return this.handleTemplateText(bot, msg)
+		}
+		// files posted in groups are not meant for the bot, as /import is offered in private chats only
+		if msg.Document != nil && msg.Chat.Type == api.PrivateChat {
+			return this.handleImportFile(bot, msg)
+		}
 		return this.handleNewCheckDescr(bot, msg)
 	} else {
-		delete(this.checkBuffer, msg.Sender.ID)
-		switch command {
-		case start:
-			bot.SendMessage(getStartMessage(msg.Chat.ID))
-		case addWhite:
-			bot.SendMessage(getSkillMessage(command, msg.Chat.ID, typRetriable))
-		case addRed:
-			bot.SendMessage(getSkillMessage(command, msg.Chat.ID, typNonRetriable))
-		case seeTop:
-			return this.displayListChecks(bot, msg)
-		default:
-			err = fmt.Errorf("unsupported command %s", command)
-			bot.SendMessage(getErrorMessage(msg.Chat.ID, err))
-			return err
+		delete(this.checkBuffer, dialogOf(msg))
+		delete(this.locationRequests, msg.Sender.ID)
+		delete(this.noteRequests, dialogOf(msg))
+		delete(this.templateBuffer, dialogOf(msg))
+		// commands of operators are unknown to others
+		if cmd, ok := this.findCommand(command); ok && (cmd.listed != listedForAdmins || this.isAdmin(msg.Sender.ID)) {
+			return cmd.handler(bot, msg)
 		}
-		return nil
+		// groups may have other bots with commands of the same names, e.g. /help
+		if msg.Chat.Type != api.PrivateChat && !api.IsCommandAddressed(*msg) {
+			return nil
+		}
+		err = newUserError("error.unsupported_command", command)
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
 	}
 }
 
 func (this *DiscoCheckBot) OnCallbackQuery(bot *api.Bot, cbq *api.CallbackQuery) error {
 	var ok bool
 	var err error
+	this.rememberUser(cbq.Sender)
+	if cbq.Message != nil {
+		this.rememberChat(cbq.Message.Chat)
+	}
+	lc := this.locale(cbq.Sender)
 	callbackParams := strings.Split(cbq.Data, "/")
 	if len(callbackParams) > 2 {
 		switch callbackParams[0] {
@@ -108,19 +227,185 @@ func (this *DiscoCheckBot) OnCallbackQuery(bot *api.Bot, cbq *api.CallbackQuery)
 					if ok, err = this.handleCheckAction(bot, cbq, callbackParams); ok {
 						return err
 					}
+				case listCheckUndo:
+					if ok, err = this.undoAttempt(bot, cbq, callbackParams); ok {
+						return err
+					}
+				case listCheckChange:
+					if ok, err = this.changeAttemptResult(bot, cbq, callbackParams); ok {
+						return err
+					}
+				case listCheckNote:
+					if ok, err = this.requestAttemptNote(bot, cbq, callbackParams); ok {
+						return err
+					}
+				case listCheckPhoto:
+					if ok, err = this.sendAttemptPhoto(bot, cbq, callbackParams); ok {
+						return err
+					}
+				case listCheckTemplate:
+					if ok, err = this.startSaveTemplate(bot, cbq, callbackParams); ok {
+						return err
+					}
+				}
+			}
+		case importChecks:
+			if ok, err = this.handleImportAction(bot, cbq, callbackParams); ok {
+				return err
+			}
+		case seeLeaderboard:
+			if ok, err = this.refreshLeaderboard(bot, cbq, callbackParams); ok {
+				return err
+			}
+		case setDue:
+			if ok, err = this.handleNewCheckDue(bot, cbq, callbackParams); ok {
+				return err
+			}
+		case seeCabinet:
+			if ok, err = this.handleCabinetAction(bot, cbq, callbackParams); ok {
+				return err
+			}
+		case useTemplate:
+			if ok, err = this.handleTemplateAction(bot, cbq, callbackParams); ok {
+				return err
+			}
+		case setLanguage:
+			if ok, err = this.handleLanguageAction(bot, cbq, callbackParams); ok {
+				return err
+			}
+		case setTimezone:
+			if ok, err = this.handleTimezoneAction(bot, cbq, callbackParams); ok {
+				return err
+			}
+		case broadcast:
+			if this.isAdmin(cbq.Sender.ID) {
+				if ok, err = this.handleBroadcastAction(bot, cbq, callbackParams); ok {
+					return err
 				}
 			}
 		}
 	}
 	err = fmt.Errorf("invalid callback data %s because of %v", cbq.Data, err)
-	bot.AnswerCallbackQuery(getErrorCbqAnswer(cbq.ID, err))
+	bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
 	return err
 }
 
+// open checks of the user are offered to be posted to any chat, the query filters them by description
+func (this *DiscoCheckBot) OnInlineQuery(bot *api.Bot, iq *api.InlineQuery) error {
+	this.rememberUser(iq.Sender)
+	lc := this.locale(iq.Sender)
+	list, err := this.db.searchOpenChecks(iq.Sender.ID, strings.TrimSpace(iq.Query), maxInlineResults)
+	if err != nil {
+		// empty answer, so the client does not wait for results
+		bot.AnswerInlineQuery(getInlineQueryAnswer(lc, iq.ID, nil, nil))
+		return err
+	}
+	bot.AnswerInlineQuery(getInlineQueryAnswer(lc, iq.ID, list, func(action int, checkId int64) string {
+		return bot.StartLink(this.links.sign(action, checkId))
+	}))
+	return nil
+}
+
+// posted card needs nothing from the bot, the choice is only logged
+func (this *DiscoCheckBot) OnChosenInlineResult(bot *api.Bot, cir *api.ChosenInlineResult) error {
+	this.rememberUser(cir.Sender)
+	if _, err := strconv.ParseInt(cir.ResultID, 10, 64); err != nil {
+		return fmt.Errorf("invalid inline result %s because of %v", cir.ResultID, err)
+	}
+	return nil
+}
+
+// arguments may contain party flag and recurrence rule, e.g. /white party weekly mon thu
+func (this *DiscoCheckBot) startNewCheck(bot *api.Bot, msg *api.Message, command string, typ int) error {
+	lc := this.locale(msg.Sender)
+	var chk check
+	rule, args, err := parseRecurrenceArgs(api.ParseCommandArgs(*msg))
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	chk.Recurrence = rule.String()
+	if slices.Contains(args, partyFlag) {
+		if msg.Chat.Type == api.PrivateChat {
+			err = newUserError("error.party_private_chat")
+			bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+			return err
+		}
+		chk.Party = true
+	}
+	if chk.Party || chk.Recurrence != "" {
+		this.checkBuffer[dialogOf(msg)] = checkDraft{chk, time.Now()}
+	}
+	bot.SendMessage(getSkillMessage(lc, command, msg.Chat.ID, typ))
+	return nil
+}
+
+// stores names of users, so they can be displayed later, e.g. in leaderboard
+func (this *DiscoCheckBot) rememberUser(sender *api.User) {
+	if sender == nil {
+		return
+	}
+	usr := user{
+		Id:           sender.ID,
+		UserName:     sender.UserName,
+		FirstName:    sender.FirstName,
+		LanguageCode: sender.LanguageCode,
+	}
+	if known, ok := this.knownUsers[usr.Id]; ok && known.UserName == usr.UserName &&
+		known.FirstName == usr.FirstName && known.LanguageCode == usr.LanguageCode {
+		return
+	}
+	if err := this.db.saveUser(&usr); err == nil {
+		usr.cacheZone()
+		this.knownUsers[usr.Id] = usr
+	}
+}
+
+// stores chats, so operators can make announcements to all of them
+func (this *DiscoCheckBot) rememberChat(cht *api.Chat) {
+	if cht == nil {
+		return
+	}
+	known := chat{Id: cht.ID, Type: cht.Type, Title: cht.Title}
+	if prev, ok := this.knownChats[known.Id]; ok && prev.Type == known.Type && prev.Title == known.Title {
+		return
+	}
+	if err := this.db.saveChat(&known); err == nil {
+		this.knownChats[known.Id] = known
+	}
+}
+
+// language and dates of the sender, as chosen or as in telegram settings
+func (this *DiscoCheckBot) locale(sender *api.User) locale {
+	if usr, ok := this.knownUsers[sender.ID]; ok {
+		return usr.locale()
+	}
+	return newLocale(sender.LanguageCode)
+}
+
+// applies changed settings to the cached user, so they take effect right away
+func (this *DiscoCheckBot) updateKnownUser(userId int64, update func(usr *user)) {
+	if usr, ok := this.knownUsers[userId]; ok {
+		update(&usr)
+		usr.cacheZone()
+		this.knownUsers[userId] = usr
+	}
+}
+
+// language of the user outside of updates, e.g. for reminders
+func (this *DiscoCheckBot) userLocale(userId int64) (locale, error) {
+	usr, err := this.db.readUser(userId)
+	if err != nil {
+		return locale{}, err
+	}
+	return usr.locale(), nil
+}
+
 func (this *DiscoCheckBot) handleNewCheckProperty(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
 	if len(clbkPar) == 3 {
 		bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
-		bot.EditMessageText(getSkillDifEditMessage(cbq.Message.Chat.ID, cbq.Message.MessageID, cbq.Data))
+		bot.EditMessageText(getSkillDifEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, cbq.Data))
 		return true, nil
 	} else if len(clbkPar) == 4 {
 		var dffclt int
@@ -137,73 +422,237 @@ func (this *DiscoCheckBot) handleNewCheckProperty(bot *api.Bot, cbq *api.Callbac
 		if dffclt, err = strconv.Atoi(clbkPar[3]); err != nil {
 			return false, err
 		}
-		chk = check{
-			Typ:        typ,
-			Skill:      skill,
-			Difficulty: dffclt,
-		}
-		this.checkBuffer[cbq.Sender.ID] = chk
+		chk = this.checkBuffer[dialogOfCallback(cbq)].chk
+		chk.Typ = typ
+		chk.Skill = skill
+		chk.Difficulty = dffclt
+		this.checkBuffer[dialogOfCallback(cbq)] = checkDraft{chk, time.Now()}
 		bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
-		bot.EditMessageText(getSkillTxtEditMessage(cbq.Message.Chat.ID, cbq.Message.MessageID, chk))
+		bot.EditMessageText(getSkillTxtEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, chk,
+			this.voices.description(lc.Language(), chk.Skill)))
 		return true, nil
 	} else {
 		return false, errors.New("invalid number of params")
 	}
 }
 
+// the message is either description or due date of the check being created
 func (this *DiscoCheckBot) handleNewCheckDescr(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
 	var err error
-	chk, ok := this.checkBuffer[msg.Sender.ID]
-	if ok && msg.Text != "" {
-		delete(this.checkBuffer, msg.Sender.ID)
-		chk.Description = msg.Text
-		chk.CreatedByUser = msg.Sender.ID
-		chk.CreatedByMessage = msg.MessageID
-		chk.CreatedByChat = msg.Chat.ID
-		if err = chk.validate(); err != nil {
-			bot.SendMessage(getErrorMessage(msg.Chat.ID, err))
-			return err
+	draft, ok := this.checkBuffer[dialogOf(msg)]
+	chk := draft.chk
+	if ok && !chk.empty() && msg.Text != "" {
+		if chk.Description == "" {
+			chk.Description = msg.Text
+			chk.CreatedByUser = msg.Sender.ID
+			chk.CreatedByMessage = msg.MessageID
+			chk.CreatedByChat = msg.Chat.ID
+			if utf8.RuneCountInString(chk.Description) > maxDescriptionLength {
+				err = newUserError("error.description_too_long", maxDescriptionLength)
+			} else {
+				err = chk.validate()
+			}
+			if err != nil {
+				delete(this.checkBuffer, dialogOf(msg))
+				bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+				return err
+			}
+			if rule := chk.rule(); !rule.empty() {
+				// recurring checks are due at their first occurrence
+				delete(this.checkBuffer, dialogOf(msg))
+				chk.DueAt = rule.first(lc.now())
+				return this.createNewCheck(bot, lc, chk)
+			}
+			this.checkBuffer[dialogOf(msg)] = checkDraft{chk, time.Now()}
+			bot.SendMessage(getDueDateMessage(lc, msg.Chat.ID, lc.now()))
+			return nil
 		}
-		if err = this.db.createCheck(&chk); err != nil {
-			bot.SendMessage(getErrorMessage(msg.Chat.ID, err))
+		if chk.DueAt, err = parseDueDate(msg.Text, lc.now()); err != nil {
+			bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
 			return err
 		}
-		if chk, err = this.db.readCheck(chk.Id); err != nil {
-			bot.SendMessage(getErrorMessage(msg.Chat.ID, err))
-		} else {
-			bot.SendMessage(getSingleCheckMessage(msg.Chat.ID, chk))
+		delete(this.checkBuffer, dialogOf(msg))
+		return this.createNewCheck(bot, lc, chk)
+	}
+	return err
+}
+
+func (this *DiscoCheckBot) handleNewCheckDue(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
+	oper, err := strconv.Atoi(clbkPar[1])
+	if err != nil {
+		return false, err
+	}
+	draft, ok := this.checkBuffer[dialogOfCallback(cbq)]
+	chk := draft.chk
+	if !ok || chk.Description == "" {
+		err = newUserError("error.no_check_awaits_due")
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	switch oper {
+	case dueSkip:
+		chk.DueAt = time.Time{}
+	case duePick:
+		if chk.DueAt, err = parsePickedDueDate(clbkPar[2], lc.now()); err != nil {
+			bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+			return true, err
+		}
+	default:
+		return false, fmt.Errorf("unsupported due date operation %d", oper)
+	}
+	delete(this.checkBuffer, dialogOfCallback(cbq))
+	bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+	bot.EditMessageText(getDueDateEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, chk.DueAt))
+	return true, this.createNewCheck(bot, lc, chk)
+}
+
+// saves completely filled check and schedules its reminders
+func (this *DiscoCheckBot) createNewCheck(bot *api.Bot, lc locale, chk check) error {
+	var err error
+	if err = chk.validate(); err != nil {
+		bot.SendMessage(getErrorMessage(lc, chk.CreatedByChat, err))
+		return err
+	}
+	if err = this.db.createCheck(&chk); err != nil {
+		bot.SendMessage(getErrorMessage(lc, chk.CreatedByChat, err))
+		return err
+	}
+	for _, rem := range chk.reminders(time.Now()) {
+		if err = this.db.createReminder(&rem); err != nil {
+			bot.SendMessage(getErrorMessage(lc, chk.CreatedByChat, err))
+			return err
 		}
 	}
+	chatId := chk.CreatedByChat
+	if chk, err = this.readCheck(chk.Id); err != nil {
+		bot.SendMessage(getErrorMessage(lc, chatId, err))
+	} else {
+		bot.SendMessage(getSingleCheckMessage(lc, chatId, chk,
+			formatVoice(lc, chk.Skill, this.voices.speak(lc.Language(), chk.Skill, voiceCreated))))
+	}
 	return err
 }
 
+// prompts are few, as they are answered or forgotten in minutes
+func (this *DiscoCheckBot) forgetStalePrompts(now time.Time) {
+	for key, draft := range this.checkBuffer {
+		if now.Sub(draft.askedAt) > promptTimeout {
+			delete(this.checkBuffer, key)
+		}
+	}
+	for userId, askedAt := range this.locationRequests {
+		if now.Sub(askedAt) > promptTimeout {
+			delete(this.locationRequests, userId)
+		}
+	}
+	for key, req := range this.noteRequests {
+		if now.Sub(req.askedAt) > promptTimeout {
+			delete(this.noteRequests, key)
+		}
+	}
+	for key, draft := range this.templateBuffer {
+		if now.Sub(draft.askedAt) > promptTimeout {
+			delete(this.templateBuffer, key)
+		}
+	}
+}
+
+// token of start link either opens the check or copies it into the user's checks
+func (this *DiscoCheckBot) handleStartLink(bot *api.Bot, msg *api.Message, token string) error {
+	lc := this.locale(msg.Sender)
+	var chk check
+	action, checkId, err := this.links.verify(token)
+	if err == nil {
+		chk, err = this.readCheck(checkId)
+	}
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	switch action {
+	case linkOpen:
+		// owner gets the check with attempt buttons, anyone else may copy it
+		own := !chk.Party && chk.CreatedByUser == msg.Sender.ID
+		if own {
+			var cabinet []cabinetThought
+			if cabinet, err = this.db.readCabinet(msg.Sender.ID); err != nil {
+				bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+				return err
+			}
+			chk.Modifier = cabinetModifiers(cabinet)[chk.Skill]
+			this.setAttemptActions(&chk, msg.Sender.ID)
+		}
+		bot.SendMessage(getLinkedCheckMessage(lc, msg.Chat.ID, chk, own, bot.StartLink(this.links.sign(linkClone, chk.Id))))
+		return nil
+	case linkClone:
+		clone := check{
+			Skill:            chk.Skill,
+			Difficulty:       chk.Difficulty,
+			Typ:              chk.Typ,
+			Description:      chk.Description,
+			Recurrence:       chk.Recurrence,
+			CreatedByUser:    msg.Sender.ID,
+			CreatedByChat:    msg.Chat.ID,
+			CreatedByMessage: msg.MessageID,
+		}
+		if rule := clone.rule(); !rule.empty() {
+			clone.DueAt = rule.first(lc.now())
+		}
+		return this.createNewCheck(bot, lc, clone)
+	default:
+		err = newUserError("error.link_invalid")
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+}
+
 func (this *DiscoCheckBot) displayCheck(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
 	var chk check
 	var err error
 	if chk.Id, err = strconv.ParseInt(clbkPar[2], 10, 64); err != nil {
 		return false, err
 	}
-	chk, err = this.db.readCheck(chk.Id)
+	chk, err = this.readCheck(chk.Id)
+	if err == nil {
+		var cabinet []cabinetThought
+		if cabinet, err = this.db.readCabinet(cbq.Sender.ID); err == nil {
+			chk.Modifier = cabinetModifiers(cabinet)[chk.Skill]
+		}
+		this.setAttemptActions(&chk, cbq.Sender.ID)
+	}
 	if err != nil {
-		bot.AnswerCallbackQuery(getErrorCbqAnswer(cbq.ID, err))
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
 	} else {
 		bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
-		bot.EditMessageText(getSingleCheckEditMessage(cbq.Message.Chat.ID, cbq.Message.MessageID, chk))
+		bot.EditMessageText(getSingleCheckEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, chk))
 	}
 	return true, err
 }
 
+// in group chats party checks of the chat are listed instead of personal ones
+func (this *DiscoCheckBot) listChecks(chat *api.Chat, userId int64, offsetId int64, desc bool) ([]check, error) {
+	if chat.Type == api.PrivateChat {
+		return this.db.listUserChecks(userId, offsetId, desc)
+	}
+	return this.db.listChatChecks(chat.ID, offsetId, desc)
+}
+
 func (this *DiscoCheckBot) displayListChecks(bot *api.Bot, msg *api.Message) error {
-	list, err := this.db.listUserChecks(msg.Sender.ID, 0, false)
+	lc := this.locale(msg.Sender)
+	list, err := this.listChecks(msg.Chat, msg.Sender.ID, 0, false)
 	if err != nil {
-		bot.SendMessage(getErrorMessage(msg.Chat.ID, err))
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
 	} else {
-		bot.SendMessage(getListCheckMessage(seeTop, msg.Chat.ID, list))
+		bot.SendMessage(getListCheckMessage(lc, seeTop, msg.Chat.ID, list))
 	}
 	return err
 }
 
 func (this *DiscoCheckBot) refreshListChecks(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
 	var nextChkId int64
 	var err error
 	list := make([]check, 0)
@@ -211,19 +660,20 @@ func (this *DiscoCheckBot) refreshListChecks(bot *api.Bot, cbq *api.CallbackQuer
 	if nextChkId, err = strconv.ParseInt(clbkPar[2], 10, 64); err != nil {
 		return false, err
 	}
-	list, err = this.db.listUserChecks(cbq.Sender.ID, nextChkId, oper == listCheckBackward)
+	list, err = this.listChecks(cbq.Message.Chat, cbq.Sender.ID, nextChkId, oper == listCheckBackward)
 	if err != nil {
-		bot.AnswerCallbackQuery(getErrorCbqAnswer(cbq.ID, err))
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
 	} else {
 		bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
 		if len(list) > 0 {
-			bot.EditMessageText(getListCheckEditMessage(cbq.Message.Chat.ID, cbq.Message.MessageID, list))
+			bot.EditMessageText(getListCheckEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, list, 0, 0))
 		}
 	}
 	return true, err
 }
 
 func (this *DiscoCheckBot) handleCheckAction(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
 	var att attempt
 	var err error
 	if att.CheckId, err = strconv.ParseInt(clbkPar[2], 10, 64); err != nil {
@@ -232,25 +682,735 @@ func (this *DiscoCheckBot) handleCheckAction(bot *api.Bot, cbq *api.CallbackQuer
 	if att.Result, err = strconv.Atoi(clbkPar[3]); err != nil {
 		return false, err
 	}
+	att.CreatedByUser = cbq.Sender.ID
 	att.CreatedByMessage = cbq.Message.MessageID
 	att.CreatedByChat = cbq.Message.Chat.ID
 	if err = att.validate(); err != nil {
-		bot.AnswerCallbackQuery(getErrorCbqAnswer(cbq.ID, err))
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	chk, err := this.db.readCheck(att.CheckId)
+	if err == nil {
+		err = chk.attemptableBy(cbq.Sender.ID, cbq.Message.Chat.ID)
+	}
+	if err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
 		return true, err
 	}
+	var finished []cabinetThought
+	prev := chk
 	err = this.db.createAttempt(&att)
+	if err == nil {
+		chk.Attempts = append(chk.Attempts, att)
+		finished, err = this.settleAttempts(prev, chk, att.CreatedByUser)
+	}
 	if err != nil {
-		bot.AnswerCallbackQuery(getErrorCbqAnswer(cbq.ID, err))
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
 	} else {
-		list, err := this.db.listUserChecks(cbq.Sender.ID, 0, false)
+		list, err := this.listChecks(cbq.Message.Chat, cbq.Sender.ID, 0, false)
 		if err != nil {
-			bot.AnswerCallbackQuery(getErrorCbqAnswer(cbq.ID, err))
+			bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
 		} else {
-			bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+			voice := this.voices.speak(lc.Language(), chk.Skill, attemptVoiceEvent(chk, att.Result))
+			bot.AnswerCallbackQuery(getAttemptCbqAnswer(lc, cbq.ID, formatVoice(lc, chk.Skill, voice), finished))
+			// undo is offered right away, so a misclick is fixed with one more click, zero window disables it
+			var undoId, noteId int64
+			if this.undoWindow > 0 {
+				undoId = att.Id
+			}
+			// canceled attempts are not worth a note
+			if att.Result != resCanceled {
+				noteId = att.Id
+			}
 			if len(list) > 0 {
-				bot.EditMessageText(getListCheckEditMessage(cbq.Message.Chat.ID, cbq.Message.MessageID, list))
+				bot.EditMessageText(getListCheckEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, list, undoId, noteId))
+			} else {
+				this.setAttemptActions(&chk, cbq.Sender.ID)
+				bot.EditMessageText(getSingleCheckEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, chk))
 			}
 		}
 	}
 	return true, err
 }
+
+// reminders and research follow the check being closed or reopened by a new, undone or changed attempt,
+// userId is the author of the attempt, whose research advances
+func (this *DiscoCheckBot) settleAttempts(prev check, next check, userId int64) ([]cabinetThought, error) {
+	if !prev.closed() && next.closed() && next.Recurrence != "" {
+		if err := this.scheduleNextInstance(next); err != nil {
+			return nil, err
+		}
+	} else if prev.closed() && !next.closed() && next.Recurrence != "" {
+		if err := this.db.cancelReminders(next.Id, remRecur); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case !prev.completed() && next.completed():
+		return this.advanceResearch(userId)
+	case prev.completed() && !next.completed():
+		return nil, this.db.retreatResearch(userId)
+	}
+	return nil, nil
+}
+
+// author may undo the last attempt for a while and annotate it any time,
+// owner of the check may change its result any time and save the check as a template
+func (this *DiscoCheckBot) setAttemptActions(chk *check, viewerId int64) {
+	last, ok := chk.lastAttempt()
+	// missed attempts are made by the bot on behalf of the owner
+	own := ok && last.CreatedByUser == viewerId && last.Result != resMissed
+	chk.CanUndo = own && time.Since(last.CreatedAt) <= this.undoWindow && !chk.HasNext
+	chk.CanChange = ok && chk.CreatedByUser == viewerId
+	chk.CanNote = own && last.Result != resCanceled
+	chk.CanSave = chk.CreatedByUser == viewerId
+}
+
+func (this *DiscoCheckBot) undoAttempt(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
+	attemptId, err := strconv.ParseInt(clbkPar[2], 10, 64)
+	if err != nil {
+		return false, err
+	}
+	att, err := this.db.readAttempt(attemptId)
+	var chk check
+	if err == nil {
+		chk, err = this.readCheck(att.CheckId)
+	}
+	if err == nil {
+		err = this.checkUndo(chk, att, cbq.Sender.ID)
+	}
+	if err == nil {
+		err = this.db.deleteAttempt(att.Id, cbq.Sender.ID)
+	}
+	if err == nil {
+		next := chk
+		next.Attempts = chk.Attempts[:len(chk.Attempts)-1]
+		_, err = this.settleAttempts(chk, next, att.CreatedByUser)
+		chk = next
+	}
+	if err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	this.setAttemptActions(&chk, cbq.Sender.ID)
+	bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, lc.T("attempt.undone")))
+	bot.EditMessageText(getSingleCheckEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, chk))
+	return true, nil
+}
+
+// only the last attempt of the author during the window, so later attempts are not broken
+func (this *DiscoCheckBot) checkUndo(chk check, att attempt, userId int64) error {
+	if att.CreatedByUser != userId || att.Result == resMissed {
+		return newUserError("error.undo_other_user")
+	}
+	if last, _ := chk.lastAttempt(); last.Id != att.Id {
+		return newUserError("error.undo_not_last")
+	}
+	if time.Since(att.CreatedAt) > this.undoWindow {
+		return newUserError("error.undo_expired", int(this.undoWindow.Minutes()))
+	}
+	// series would have two open instances
+	if chk.HasNext {
+		return newUserError("error.next_instance_opened")
+	}
+	return nil
+}
+
+// owner of the check corrects result of the last attempt, the change is kept in history
+func (this *DiscoCheckBot) changeAttemptResult(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
+	if len(clbkPar) < 4 {
+		return false, fmt.Errorf("result is missing")
+	}
+	attemptId, err := strconv.ParseInt(clbkPar[2], 10, 64)
+	if err != nil {
+		return false, err
+	}
+	result, err := strconv.Atoi(clbkPar[3])
+	if err != nil {
+		return false, err
+	}
+	att, err := this.db.readAttempt(attemptId)
+	var chk, next check
+	if err == nil {
+		chk, err = this.readCheck(att.CheckId)
+	}
+	if err == nil {
+		next, err = checkChange(chk, att, result, cbq.Sender.ID)
+	}
+	if err == nil {
+		err = this.db.changeAttemptResult(att.Id, result, cbq.Sender.ID)
+	}
+	var finished []cabinetThought
+	if err == nil {
+		finished, err = this.settleAttempts(chk, next, att.CreatedByUser)
+		chk = next
+	}
+	if err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	this.setAttemptActions(&chk, cbq.Sender.ID)
+	bot.AnswerCallbackQuery(getAttemptCbqAnswer(lc, cbq.ID, lc.T("attempt.changed", lc.result(result)), finished))
+	bot.EditMessageText(getSingleCheckEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, chk))
+	return true, nil
+}
+
+// returns the check with the changed result of its last attempt, made by anyone,
+// series instance may not be reopened, when the next one is opened
+func checkChange(chk check, att attempt, result int, userId int64) (check, error) {
+	if chk.CreatedByUser != userId {
+		return check{}, newUserError("error.change_other_user", chk.Id)
+	}
+	if last, _ := chk.lastAttempt(); last.Id != att.Id {
+		return check{}, newUserError("error.undo_not_last")
+	}
+	att.Result = result
+	if err := att.validate(); err != nil {
+		return check{}, err
+	}
+	next := chk
+	next.Attempts = slices.Clone(chk.Attempts)
+	next.Attempts[len(next.Attempts)-1].Result = result
+	if chk.HasNext && !next.closed() {
+		return check{}, newUserError("error.next_instance_opened")
+	}
+	return next, nil
+}
+
+// the next message of the author in the chat becomes the note, see handleAttemptNote
+func (this *DiscoCheckBot) requestAttemptNote(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
+	attemptId, err := strconv.ParseInt(clbkPar[2], 10, 64)
+	if err != nil {
+		return false, err
+	}
+	att, err := this.db.readAttempt(attemptId)
+	if err == nil && att.CreatedByUser != cbq.Sender.ID {
+		err = newUserError("error.note_other_user")
+	}
+	if err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	this.noteRequests[dialogOfCallback(cbq)] = noteRequest{att.Id, time.Now()}
+	bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+	bot.SendMessage(getTextMessage(cbq.Message.Chat.ID, lc.T("attempt.note_prompt", maxNoteLength)))
+	return true, nil
+}
+
+// text or caption of a photo is the note, the largest size of the photo is kept,
+// the check is sent again with the note in its timeline
+func (this *DiscoCheckBot) handleAttemptNote(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
+	attemptId := this.noteRequests[dialogOf(msg)].attemptId
+	delete(this.noteRequests, dialogOf(msg))
+	note := strings.TrimSpace(msg.Text)
+	var photoId string
+	if len(msg.Photo) > 0 {
+		note = strings.TrimSpace(msg.Caption)
+		photoId = msg.Photo[len(msg.Photo)-1].FileID
+	}
+	var err error
+	if note == "" && photoId == "" {
+		err = newUserError("error.note_empty")
+	} else if utf8.RuneCountInString(note) > maxNoteLength {
+		err = newUserError("error.note_too_long", maxNoteLength)
+	}
+	var att attempt
+	if err == nil {
+		att, err = this.db.readAttempt(attemptId)
+	}
+	if err == nil && att.CreatedByUser != msg.Sender.ID {
+		err = newUserError("error.note_other_user")
+	}
+	if err == nil {
+		err = this.db.setAttemptNote(att.Id, note, photoId)
+	}
+	var chk check
+	if err == nil {
+		chk, err = this.readCheck(att.CheckId)
+	}
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	this.setAttemptActions(&chk, msg.Sender.ID)
+	bot.SendMessage(getTextMessage(msg.Chat.ID, lc.T("attempt.note_saved")))
+	bot.SendMessage(getCheckCardMessage(lc, msg.Chat.ID, chk))
+	return nil
+}
+
+// photo is sent by file id, telegram keeps the file, the note is its caption
+func (this *DiscoCheckBot) sendAttemptPhoto(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
+	attemptId, err := strconv.ParseInt(clbkPar[2], 10, 64)
+	if err != nil {
+		return false, err
+	}
+	att, err := this.db.readAttempt(attemptId)
+	if err == nil && att.PhotoId == "" {
+		err = newUserError("error.photo_missing")
+	}
+	if err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+	bot.SendPhoto(api.SendPhoto{ChatID: cbq.Message.Chat.ID, Photo: att.PhotoId, Caption: att.Note})
+	return true, nil
+}
+
+func (this *DiscoCheckBot) handleImportFile(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
+	delete(this.importBuffer, msg.Sender.ID)
+	file, err := bot.GetFile(msg.Document.FileID)
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	content, err := bot.DownloadFile(file, maxImportFileSize)
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	list, err := parseImportFile(content, time.Now().In(lc.zone), msg.Sender.ID, msg.Chat.ID, msg.MessageID)
+	if err != nil {
+		err = newUserError("error.import_failed", msg.Document.FileName, lc.error(err))
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	this.importBuffer[msg.Sender.ID] = list
+	bot.SendMessage(getImportPreviewMessage(lc, msg.Chat.ID, list))
+	return nil
+}
+
+func (this *DiscoCheckBot) handleImportAction(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
+	oper, err := strconv.Atoi(clbkPar[1])
+	if err != nil {
+		return false, err
+	}
+	list, ok := this.importBuffer[cbq.Sender.ID]
+	delete(this.importBuffer, cbq.Sender.ID)
+	switch oper {
+	case importConfirm:
+		if !ok {
+			err = newUserError("error.nothing_to_import")
+			bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+			return true, err
+		}
+		if err = this.db.importChecks(list); err != nil {
+			bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+			return true, err
+		}
+		bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+		bot.EditMessageText(getImportResultEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, list))
+		return true, nil
+	case importCancel:
+		bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+		bot.EditMessageText(getImportResultEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, nil))
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported import operation %d", oper)
+	}
+}
+
+// chosen language is applied right away, the confirmation is in that language already
+func (this *DiscoCheckBot) handleLanguageAction(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	var language string
+	oper, err := strconv.Atoi(clbkPar[1])
+	if err != nil {
+		return false, err
+	}
+	switch oper {
+	case languageAuto:
+	case languageSet:
+		languages := i18n.Default.Languages()
+		index, err := strconv.Atoi(clbkPar[2])
+		if err != nil {
+			return false, err
+		}
+		if index < 0 || index >= len(languages) {
+			return false, fmt.Errorf("invalid language %d", index)
+		}
+		language = languages[index]
+	default:
+		return false, fmt.Errorf("unsupported language operation %d", oper)
+	}
+	if err = this.db.setUserLanguage(cbq.Sender.ID, language); err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(this.locale(cbq.Sender), cbq.ID, err))
+		return true, err
+	}
+	this.updateKnownUser(cbq.Sender.ID, func(usr *user) { usr.Language = language })
+	lc := this.locale(cbq.Sender)
+	bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+	bot.EditMessageText(getLanguageEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID))
+	return true, nil
+}
+
+// city and date format are changed right in the message, location is requested with reply keyboard
+func (this *DiscoCheckBot) handleTimezoneAction(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	oper, err := strconv.Atoi(clbkPar[1])
+	if err != nil {
+		return false, err
+	}
+	index, err := strconv.Atoi(clbkPar[2])
+	if err != nil {
+		return false, err
+	}
+	switch oper {
+	case timezoneCity:
+		if index < 0 || index >= len(timezoneCities) {
+			return false, fmt.Errorf("invalid city %d", index)
+		}
+		timezone := timezoneCities[index]
+		if err = this.db.setUserTimezone(cbq.Sender.ID, timezone); err == nil {
+			this.updateKnownUser(cbq.Sender.ID, func(usr *user) { usr.Timezone = timezone })
+		}
+	case timezoneFormat:
+		if index < 0 || index >= len(dateFormats) {
+			return false, fmt.Errorf("invalid date format %d", index)
+		}
+		dateFormat := dateFormats[index]
+		if err = this.db.setUserDateFormat(cbq.Sender.ID, dateFormat); err == nil {
+			this.updateKnownUser(cbq.Sender.ID, func(usr *user) { usr.DateFormat = dateFormat })
+		}
+	case timezoneLocation:
+		this.locationRequests[cbq.Sender.ID] = time.Now()
+		bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+		bot.SendReplyKeyboard(getLocationRequestMessage(this.locale(cbq.Sender), cbq.Message.Chat.ID))
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported time zone operation %d", oper)
+	}
+	lc := this.locale(cbq.Sender)
+	if err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+	bot.EditMessageText(getTimezoneEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID,
+		cbq.Message.Chat.Type == api.PrivateChat))
+	return true, nil
+}
+
+// shared location sets time zone, any other message cancels the request
+func (this *DiscoCheckBot) handleLocation(bot *api.Bot, msg *api.Message) error {
+	delete(this.locationRequests, msg.Sender.ID)
+	if msg.Location == nil {
+		bot.SendReplyKeyboard(getLocationResultMessage(this.locale(msg.Sender), msg.Chat.ID, false))
+		return nil
+	}
+	timezone := zoneByLongitude(msg.Location.Longitude)
+	if err := this.db.setUserTimezone(msg.Sender.ID, timezone); err != nil {
+		bot.SendMessage(getErrorMessage(this.locale(msg.Sender), msg.Chat.ID, err))
+		return err
+	}
+	this.updateKnownUser(msg.Sender.ID, func(usr *user) { usr.Timezone = timezone })
+	bot.SendReplyKeyboard(getLocationResultMessage(this.locale(msg.Sender), msg.Chat.ID, true))
+	return nil
+}
+
+func (this *DiscoCheckBot) displayLeaderboard(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
+	if msg.Chat.Type == api.PrivateChat {
+		err := newUserError("error.leaderboard_private_chat")
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	rows, err := this.db.readChatLeaderboard(msg.Chat.ID, periodDays[periodWeek])
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+	} else {
+		bot.SendMessage(getLeaderboardMessage(lc, msg.Chat.ID, periodWeek, rows))
+	}
+	return err
+}
+
+func (this *DiscoCheckBot) refreshLeaderboard(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
+	period, err := strconv.Atoi(clbkPar[1])
+	if err != nil {
+		return false, err
+	}
+	if period < periodWeek || period > periodAll {
+		return false, fmt.Errorf("invalid period %d", period)
+	}
+	rows, err := this.db.readChatLeaderboard(cbq.Message.Chat.ID, periodDays[period])
+	if err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+	} else {
+		bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+		bot.EditMessageText(getLeaderboardEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, period, rows))
+	}
+	return true, err
+}
+
+// sends reminders about due checks, meant to be run in its own goroutine,
+// pending reminders are kept in database, so they are sent after restart too
+func (this *DiscoCheckBot) RunReminders(bot *api.Bot, log *slog.Logger) {
+	for {
+		if err := this.sendPendingReminders(bot, log); err != nil {
+			log.Error("sending reminders failed", "error", err)
+		}
+		time.Sleep(reminderPollInterval)
+	}
+}
+
+// reminders are due in order, a failed one is postponed, so it does not hold the rest of the queue
+func (this *DiscoCheckBot) sendPendingReminders(bot *api.Bot, log *slog.Logger) error {
+	list, err := this.db.listPendingReminders(maxRemindersAtPoll)
+	if err != nil {
+		return err
+	}
+	for _, rem := range list {
+		if err = this.sendReminder(bot, rem); err != nil {
+			log.Error("sending reminder failed", "error", err,
+				"reminder_id", rem.Id,
+				logging.CheckIDKey, rem.CheckId)
+			if err = this.db.postponeReminder(rem.Id, reminderRetryDelay, maxReminderFailures); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = this.db.markReminderSent(rem.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *DiscoCheckBot) sendReminder(bot *api.Bot, rem reminder) error {
+	chk, err := this.db.readCheck(rem.CheckId)
+	if err != nil {
+		return err
+	}
+	// party reminders are in the language of the check owner too
+	lc, err := this.userLocale(chk.CreatedByUser)
+	if err != nil {
+		return err
+	}
+	if rem.Kind == remRecur {
+		return this.openNextInstance(bot, lc, chk)
+	}
+	if rem.Kind == remOverdue && chk.Recurrence != "" && !chk.closed() {
+		if chk, err = this.missInstance(chk); err != nil {
+			return err
+		}
+		bot.SendMessage(getReminderMessage(lc, chk.CreatedByChat, chk, rem.Kind))
+		return nil
+	}
+	if !chk.closed() {
+		bot.SendMessage(getReminderMessage(lc, chk.CreatedByChat, chk, rem.Kind))
+	}
+	return nil
+}
+
+// reads check, recurring one with its streak
+func (this *DiscoCheckBot) readCheck(checkId int64) (check, error) {
+	chk, err := this.db.readCheck(checkId)
+	if err != nil || chk.Recurrence == "" {
+		return chk, err
+	}
+	instances, err := this.db.listSeriesInstances(chk.SeriesId)
+	if err != nil {
+		return check{}, err
+	}
+	chk.Streak = seriesStreak(instances, time.Now())
+	// instances are sorted from the newest
+	chk.HasNext = len(instances) > 0 && instances[0].Id != chk.Id
+	return chk, nil
+}
+
+// next instance of closed recurring check is opened when its period ends
+func (this *DiscoCheckBot) scheduleNextInstance(chk check) error {
+	rem := reminder{
+		CheckId:  chk.Id,
+		Kind:     remRecur,
+		RemindAt: chk.DueAt,
+	}
+	if now := time.Now(); rem.RemindAt.Before(now) {
+		rem.RemindAt = now
+	}
+	return this.db.createReminder(&rem)
+}
+
+// instance open at the end of its period is closed as missed, so the streak breaks
+// and the next instance is opened, as after any closing attempt
+func (this *DiscoCheckBot) missInstance(chk check) (check, error) {
+	att := attempt{
+		CheckId:          chk.Id,
+		Result:           resMissed,
+		CreatedByUser:    chk.CreatedByUser,
+		CreatedByChat:    chk.CreatedByChat,
+		CreatedByMessage: chk.CreatedByMessage,
+	}
+	// metadata is taken from the valid check, and the result is not allowed to users
+	if err := this.db.createAttempt(&att); err != nil {
+		return chk, err
+	}
+	next := chk
+	next.Attempts = append(slices.Clone(chk.Attempts), att)
+	_, err := this.settleAttempts(chk, next, att.CreatedByUser)
+	return next, err
+}
+
+// instance is opened once per occurrence, a retry after failure finds it already opened
+func (this *DiscoCheckBot) openNextInstance(bot *api.Bot, lc locale, prev check) error {
+	chk := check{
+		Skill:            prev.Skill,
+		Difficulty:       prev.Difficulty,
+		Typ:              prev.Typ,
+		Description:      prev.Description,
+		Party:            prev.Party,
+		Recurrence:       prev.Recurrence,
+		SeriesId:         prev.SeriesId,
+		DueAt:            prev.nextDue(lc.now()),
+		CreatedByUser:    prev.CreatedByUser,
+		CreatedByChat:    prev.CreatedByChat,
+		CreatedByMessage: prev.CreatedByMessage,
+	}
+	if err := chk.validate(); err != nil {
+		return err
+	}
+	if err := this.db.createCheck(&chk); errors.Is(err, errInstanceExists) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, rem := range chk.reminders(time.Now()) {
+		if err := this.db.createReminder(&rem); err != nil {
+			return err
+		}
+	}
+	chk, err := this.readCheck(chk.Id)
+	if err != nil {
+		return err
+	}
+	bot.SendMessage(getReminderMessage(lc, chk.CreatedByChat, chk, remRecur))
+	return nil
+}
+
+// counts completed checks for thoughts being researched and finishes ones that are done
+func (this *DiscoCheckBot) advanceResearch(userId int64) ([]cabinetThought, error) {
+	if err := this.db.advanceResearch(userId); err != nil {
+		return nil, err
+	}
+	_, finished, err := this.readCabinet(userId)
+	return finished, err
+}
+
+// reads cabinet, thoughts which research is over are internalized on the way
+func (this *DiscoCheckBot) readCabinet(userId int64) ([]cabinetThought, []cabinetThought, error) {
+	var finished []cabinetThought
+	cabinet, err := this.db.readCabinet(userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	for i := range cabinet {
+		if cabinet[i].researching() && cabinet[i].researched(now) {
+			if err = this.db.finishCabinetThought(&cabinet[i]); err != nil {
+				return nil, nil, err
+			}
+			finished = append(finished, cabinet[i])
+		}
+	}
+	return cabinet, finished, nil
+}
+
+func (this *DiscoCheckBot) displayCabinet(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
+	cabinet, _, err := this.readCabinet(msg.Sender.ID)
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+	} else {
+		bot.SendMessage(getCabinetMessage(lc, msg.Chat.ID, msg.Sender.ID, cabinet))
+	}
+	return err
+}
+
+// callback contains owner of the cabinet, so nobody else can manage it from group chat
+func (this *DiscoCheckBot) handleCabinetAction(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
+	var oper, slot, thought int
+	var userId int64
+	var err error
+	if oper, err = strconv.Atoi(clbkPar[1]); err != nil {
+		return false, err
+	}
+	if userId, err = strconv.ParseInt(clbkPar[2], 10, 64); err != nil {
+		return false, err
+	}
+	if len(clbkPar) > 3 {
+		if slot, err = strconv.Atoi(clbkPar[3]); err != nil {
+			return false, err
+		}
+	}
+	if len(clbkPar) > 4 {
+		if thought, err = strconv.Atoi(clbkPar[4]); err != nil {
+			return false, err
+		}
+	}
+	if userId != cbq.Sender.ID {
+		err = newUserError("error.not_your_cabinet")
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	switch oper {
+	case cabinetView:
+	case cabinetChoose:
+		cabinet, _, err := this.readCabinet(userId)
+		if err != nil {
+			bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+			return true, err
+		}
+		bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+		bot.EditMessageText(getThoughtChoiceEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, userId, slot, cabinet))
+		return true, nil
+	case cabinetInternalize:
+		err = this.internalizeThought(userId, slot, thought)
+	case cabinetForget:
+		err = this.db.forgetCabinetThought(userId, slot)
+	default:
+		return false, fmt.Errorf("unsupported cabinet operation %d", oper)
+	}
+	if err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	cabinet, finished, err := this.readCabinet(userId)
+	if err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, getThoughtsFinishedText(lc, finished)))
+	bot.EditMessageText(getCabinetEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, userId, cabinet))
+	return true, nil
+}
+
+func (this *DiscoCheckBot) internalizeThought(userId int64, slot int, thought int) error {
+	cabinet, _, err := this.readCabinet(userId)
+	if err != nil {
+		return err
+	}
+	for _, ct := range cabinet {
+		if ct.Slot == slot {
+			return newUserError("error.slot_occupied", slot)
+		}
+		if ct.Thought == thought {
+			return newUserError("error.thought_in_cabinet")
+		}
+	}
+	ct := cabinetThought{
+		UserId:  userId,
+		Thought: thought,
+		Slot:    slot,
+	}
+	if err = ct.validate(); err != nil {
+		return err
+	}
+	return this.db.createCabinetThought(&ct)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"discocheckbot/api"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// records calls made when attempts are settled, other methods of the database are not used
+type settleDb struct {
+	dbAdapter
+	calls []string
+}
+
+func (this *settleDb) createReminder(rem *reminder) error {
+	this.calls = append(this.calls, "createReminder")
+	return nil
+}
+
+func (this *settleDb) cancelReminders(checkId int64, kind int) error {
+	this.calls = append(this.calls, "cancelReminders")
+	return nil
+}
+
+func (this *settleDb) advanceResearch(userId int64) error {
+	this.calls = append(this.calls, "advanceResearch")
+	return nil
+}
+
+func (this *settleDb) retreatResearch(userId int64) error {
+	this.calls = append(this.calls, "retreatResearch")
+	return nil
+}
+
+func (this *settleDb) readCabinet(userId int64) ([]cabinetThought, error) {
+	return nil, nil
+}
+
+// check of the owner 1 with its attempts, the last one is of the author 2 made minutes ago
+func checkWithAttempt(typ int, result int, minutesAgo int) check {
+	return check{Id: 10, Typ: typ, CreatedByUser: 1, Attempts: []attempt{
+		{Id: 100, CheckId: 10, Result: resFailure, CreatedByUser: 1, CreatedByChat: 1, CreatedByMessage: 1,
+			CreatedAt: time.Now().Add(-time.Hour)},
+		{Id: 101, CheckId: 10, Result: result, CreatedByUser: 2, CreatedByChat: 2, CreatedByMessage: 2,
+			CreatedAt: time.Now().Add(-time.Duration(minutesAgo) * time.Minute)},
+	}}
+}
+
+func userErrorKey(err error) string {
+	var uerr userError
+	if errors.As(err, &uerr) {
+		return uerr.key
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func TestCheckUndo(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  time.Duration
+		chk     check
+		attempt int
+		userId  int64
+		wantErr string
+	}{
+		{name: "within window", window: 5 * time.Minute, chk: checkWithAttempt(typRetriable, resSuccess, 1), attempt: 1, userId: 2},
+		{name: "window expired", window: 5 * time.Minute, chk: checkWithAttempt(typRetriable, resSuccess, 6), attempt: 1, userId: 2, wantErr: "error.undo_expired"},
+		{name: "zero window", chk: checkWithAttempt(typRetriable, resSuccess, 0), attempt: 1, userId: 2, wantErr: "error.undo_expired"},
+		{name: "owner of the check", window: 5 * time.Minute, chk: checkWithAttempt(typRetriable, resSuccess, 1), attempt: 1, userId: 1, wantErr: "error.undo_other_user"},
+		{name: "missed attempt", window: 5 * time.Minute, chk: checkWithAttempt(typRetriable, resMissed, 1), attempt: 1, userId: 2, wantErr: "error.undo_other_user"},
+		{name: "not last attempt", window: 2 * time.Hour, chk: checkWithAttempt(typRetriable, resSuccess, 1), attempt: 0, userId: 1, wantErr: "error.undo_not_last"},
+		{
+			name:    "next instance opened",
+			window:  5 * time.Minute,
+			chk:     func() check { chk := checkWithAttempt(typRetriable, resSuccess, 1); chk.HasNext = true; return chk }(),
+			attempt: 1,
+			userId:  2,
+			wantErr: "error.next_instance_opened",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := &DiscoCheckBot{undoWindow: tt.window}
+			err := bot.checkUndo(tt.chk, tt.chk.Attempts[tt.attempt], tt.userId)
+			if got := userErrorKey(err); got != tt.wantErr {
+				t.Errorf("error = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckChange(t *testing.T) {
+	series := func(result int) check {
+		chk := checkWithAttempt(typRetriable, result, 1)
+		chk.Recurrence = "daily"
+		chk.HasNext = true
+		return chk
+	}
+	tests := []struct {
+		name    string
+		chk     check
+		attempt int
+		result  int
+		userId  int64
+		wantErr string
+	}{
+		{name: "owner changes the result", chk: checkWithAttempt(typRetriable, resFailure, 90), attempt: 1, result: resSuccess, userId: 1},
+		{name: "author of the attempt", chk: checkWithAttempt(typRetriable, resFailure, 1), attempt: 1, result: resSuccess, userId: 2, wantErr: "error.change_other_user"},
+		{name: "not last attempt", chk: checkWithAttempt(typRetriable, resFailure, 1), attempt: 0, result: resSuccess, userId: 1, wantErr: "error.undo_not_last"},
+		{name: "missed result", chk: checkWithAttempt(typRetriable, resFailure, 1), attempt: 1, result: resMissed, userId: 1, wantErr: "invalid result 4"},
+		{name: "closed instance after the next one opened", chk: series(resSuccess), attempt: 1, result: resCanceled, userId: 1},
+		{name: "reopened instance after the next one opened", chk: series(resSuccess), attempt: 1, result: resFailure, userId: 1, wantErr: "error.next_instance_opened"},
+		{name: "missed instance after the next one opened", chk: series(resMissed), attempt: 1, result: resSuccess, userId: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.chk.Attempts[1].Result
+			next, err := checkChange(tt.chk, tt.chk.Attempts[tt.attempt], tt.result, tt.userId)
+			if got := userErrorKey(err); got != tt.wantErr {
+				t.Fatalf("error = %q, want %q", got, tt.wantErr)
+			}
+			if tt.chk.Attempts[1].Result != before {
+				t.Error("attempts of the check are changed")
+			}
+			if err == nil && next.Attempts[1].Result != tt.result {
+				t.Errorf("result = %d, want %d", next.Attempts[1].Result, tt.result)
+			}
+		})
+	}
+}
+
+func TestSettleAttempts(t *testing.T) {
+	open := check{Id: 10, Typ: typRetriable, Attempts: []attempt{{Result: resFailure}}}
+	withResult := func(chk check, result int) check {
+		chk.Attempts = append(append([]attempt{}, chk.Attempts...), attempt{Result: result})
+		return chk
+	}
+	recurring := open
+	recurring.Recurrence = "daily"
+	tests := []struct {
+		name      string
+		prev      check
+		next      check
+		wantCalls []string
+	}{
+		{name: "failed again", prev: open, next: withResult(open, resFailure)},
+		{name: "completed", prev: open, next: withResult(open, resSuccess), wantCalls: []string{"advanceResearch"}},
+		{name: "canceled", prev: open, next: withResult(open, resCanceled)},
+		{name: "success undone", prev: withResult(open, resSuccess), next: open, wantCalls: []string{"retreatResearch"}},
+		{name: "success changed to cancel", prev: withResult(open, resSuccess), next: withResult(open, resCanceled), wantCalls: []string{"retreatResearch"}},
+		{name: "cancel changed to success", prev: withResult(open, resCanceled), next: withResult(open, resSuccess), wantCalls: []string{"advanceResearch"}},
+		{name: "instance completed", prev: recurring, next: withResult(recurring, resSuccess), wantCalls: []string{"createReminder", "advanceResearch"}},
+		{name: "instance reopened", prev: withResult(recurring, resSuccess), next: recurring, wantCalls: []string{"cancelReminders", "retreatResearch"}},
+		{name: "missed instance", prev: recurring, next: withResult(recurring, resMissed), wantCalls: []string{"createReminder"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &settleDb{}
+			bot := &DiscoCheckBot{db: db}
+			if _, err := bot.settleAttempts(tt.prev, tt.next, 2); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(db.calls, tt.wantCalls) {
+				t.Errorf("calls = %q, want %q", db.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDialogOf(t *testing.T) {
+	user := &api.User{ID: 1}
+	bot := &DiscoCheckBot{noteRequests: make(map[dialog]noteRequest)}
+	// note is asked with the button of the message in chat A
+	cbq := &api.CallbackQuery{Sender: user, Message: &api.Message{Chat: &api.Chat{ID: -100}}}
+	bot.noteRequests[dialogOfCallback(cbq)] = noteRequest{42, time.Now()}
+	tests := []struct {
+		name string
+		msg  *api.Message
+		want int64
+	}{
+		{name: "answer in chat A", msg: &api.Message{Sender: user, Chat: &api.Chat{ID: -100}}, want: 42},
+		{name: "message in chat B", msg: &api.Message{Sender: user, Chat: &api.Chat{ID: -200}}},
+		{name: "private chat", msg: &api.Message{Sender: user, Chat: &api.Chat{ID: 1}}},
+		{name: "other user in chat A", msg: &api.Message{Sender: &api.User{ID: 2}, Chat: &api.Chat{ID: -100}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bot.noteRequests[dialogOf(tt.msg)].attemptId; got != tt.want {
+				t.Errorf("note is asked for attempt %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForgetStalePrompts(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fresh, stale := dialog{1, -100}, dialog{1, -200}
+	bot := &DiscoCheckBot{
+		checkBuffer: map[dialog]checkDraft{
+			fresh: {askedAt: now.Add(-promptTimeout)},
+			stale: {askedAt: now.Add(-promptTimeout - time.Second)},
+		},
+		locationRequests: map[int64]time.Time{
+			1: now.Add(-time.Minute),
+			2: now.Add(-promptTimeout - time.Second),
+		},
+		noteRequests: map[dialog]noteRequest{
+			fresh: {1, now},
+			stale: {2, now.Add(-time.Hour)},
+		},
+		templateBuffer: map[dialog]templateDraft{
+			fresh: {askedAt: now.Add(-time.Minute)},
+			stale: {askedAt: now.Add(-promptTimeout - time.Second)},
+		},
+	}
+	bot.forgetStalePrompts(now)
+	if _, ok := bot.checkBuffer[fresh]; !ok || len(bot.checkBuffer) != 1 {
+		t.Errorf("check drafts = %v, want the fresh one", bot.checkBuffer)
+	}
+	if _, ok := bot.locationRequests[1]; !ok || len(bot.locationRequests) != 1 {
+		t.Errorf("location requests = %v, want the fresh one", bot.locationRequests)
+	}
+	if _, ok := bot.noteRequests[fresh]; !ok || len(bot.noteRequests) != 1 {
+		t.Errorf("note requests = %v, want the fresh one", bot.noteRequests)
+	}
+	if _, ok := bot.templateBuffer[fresh]; !ok || len(bot.templateBuffer) != 1 {
+		t.Errorf("template drafts = %v, want the fresh one", bot.templateBuffer)
+	}
+}
+
+func TestTemplateDraftDialog(t *testing.T) {
+	user := &api.User{ID: 1}
+	bot := &DiscoCheckBot{templateBuffer: make(map[dialog]templateDraft)}
+	// values of placeholders are asked with the button of the message in chat A
+	cbq := &api.CallbackQuery{Sender: user, Message: &api.Message{Chat: &api.Chat{ID: -100}}}
+	bot.templateBuffer[dialogOfCallback(cbq)] = templateDraft{tmpl: template{Id: 7}, askedAt: time.Now()}
+	if got := bot.templateBuffer[dialogOf(&api.Message{Sender: user, Chat: &api.Chat{ID: -100}})].tmpl.Id; got != 7 {
+		t.Errorf("template %d is filled in chat A, want 7", got)
+	}
+	if _, ok := bot.templateBuffer[dialogOf(&api.Message{Sender: user, Chat: &api.Chat{ID: -200}})]; ok {
+		t.Error("template is filled from chat B")
+	}
+}
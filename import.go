@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxImportFileSize int64 = 1 << 20
+	maxImportChecks   int   = 1000
+)
+
+// layouts accepted for created_at columns, the last ones are what the bot itself displays
+var importTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2.01.2006 15:04",
+	"2.01.2006",
+}
+
+// json representation of a check, it is what export produces
+type importedCheck struct {
+	Typ         importedEnum      `json:"type"`
+	Skill       importedEnum      `json:"skill"`
+	Difficulty  importedEnum      `json:"difficulty"`
+	Description string            `json:"description"`
+	CreatedAt   string            `json:"created_at"`
+	Attempts    []importedAttempt `json:"attempts"`
+}
+
+type importedAttempt struct {
+	Result    importedEnum `json:"result"`
+	CreatedAt string       `json:"created_at"`
+}
+
+// either numeric identifier or its text, e.g. 1 or "Logic"
+type importedEnum string
+
+func (this *importedEnum) UnmarshalJSON(data []byte) error {
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err == nil {
+		*this = importedEnum(num.String())
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	*this = importedEnum(str)
+	return nil
+}
+
+// numeric identifier or name, see lookupName, so a file written with names of any language is accepted
+func (this importedEnum) resolve(catalogKey string, names ...[]string) (int, error) {
+	value := strings.TrimSpace(string(this))
+	if id, err := strconv.Atoi(value); err == nil {
+		return id, nil
+	}
+	if id, ok := lookupName(value, catalogKey, names...); ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("unknown value %q", value)
+}
+
+// times without offset are in the zone of the user
+func parseImportTime(value string, zone *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, zone); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %q", value)
+}
+
+// parses file content, JSON export or CSV with header, into checks with attempts,
+// metadata is taken from the message the file was sent with, times without offset are in the zone of now
+func parseImportFile(content []byte, now time.Time, userId int64, chatId int64, msgId int) ([]check, error) {
+	var imported []importedCheck
+	var err error
+	if int64(len(content)) > maxImportFileSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxImportFileSize)
+	}
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 {
+		return nil, errors.New("file is empty")
+	}
+	if trimmed[0] == '[' || trimmed[0] == '{' {
+		imported, err = parseImportJson(trimmed)
+	} else {
+		imported, err = parseImportCsv(trimmed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(imported) == 0 {
+		return nil, errors.New("file contains no checks")
+	}
+	if len(imported) > maxImportChecks {
+		return nil, fmt.Errorf("file contains %d checks, maximum %d", len(imported), maxImportChecks)
+	}
+	result := make([]check, 0, len(imported))
+	for i, imp := range imported {
+		chk, err := imp.toCheck(now, userId, chatId, msgId)
+		if err != nil {
+			return nil, fmt.Errorf("check %d: %w", i+1, err)
+		}
+		result = append(result, chk)
+	}
+	return result, nil
+}
+
+func parseImportJson(content []byte) ([]importedCheck, error) {
+	var list []importedCheck
+	if content[0] == '{' {
+		// export wrapped into object
+		var wrapper struct {
+			Checks []importedCheck `json:"checks"`
+		}
+		if err := json.Unmarshal(content, &wrapper); err != nil {
+			return nil, err
+		}
+		return wrapper.Checks, nil
+	}
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// expects header with columns type, skill, difficulty, description,
+// optional created_at and results, results are separated by spaces or "|"
+func parseImportCsv(content []byte) ([]importedCheck, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	firstLine, _, _ := bytes.Cut(content, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';' // spreadsheets in some locales
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, col := range header {
+		// e.g. "Created At" and "created_at" are the same column
+		columns[strings.ReplaceAll(normalizeName(col), " ", "")] = i
+	}
+	for _, required := range []string{"type", "skill", "difficulty", "description"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("column %q is missing in header", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var list []importedCheck
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		imp := importedCheck{
+			Typ:         importedEnum(field(record, "type")),
+			Skill:       importedEnum(field(record, "skill")),
+			Difficulty:  importedEnum(field(record, "difficulty")),
+			Description: field(record, "description"),
+			CreatedAt:   field(record, "createdat"),
+		}
+		results := strings.FieldsFunc(field(record, "results"), func(r rune) bool {
+			return r == '|' || unicode.IsSpace(r)
+		})
+		for _, res := range results {
+			// marks of displayed names, e.g. "Success 🟢", are not results themselves
+			if normalizeName(res) == "" {
+				continue
+			}
+			imp.Attempts = append(imp.Attempts, importedAttempt{Result: importedEnum(res)})
+		}
+		list = append(list, imp)
+	}
+	return list, nil
+}
+
+// attempts are read back in order of their times, so every attempt gets a time after the previous one,
+// undated ones and ones of the same time a second after it
+func (this importedCheck) toCheck(now time.Time, userId int64, chatId int64, msgId int) (check, error) {
+	var chk check
+	var err error
+	if chk.Typ, err = this.Typ.resolve("type.", typeNames[:], typeLabels[:]); err != nil {
+		return check{}, fmt.Errorf("type: %w", err)
+	}
+	if chk.Skill, err = this.Skill.resolve("skill.", skillNames[:]); err != nil {
+		return check{}, fmt.Errorf("skill: %w", err)
+	}
+	if chk.Difficulty, err = this.Difficulty.resolve("difficulty.", difficultyNames[:]); err != nil {
+		return check{}, fmt.Errorf("difficulty: %w", err)
+	}
+	if chk.CreatedAt, err = parseImportTime(this.CreatedAt, now.Location()); err != nil {
+		return check{}, err
+	}
+	chk.Description = strings.TrimSpace(this.Description)
+	if utf8.RuneCountInString(chk.Description) > maxDescriptionLength {
+		return check{}, fmt.Errorf("description is longer than %d characters", maxDescriptionLength)
+	}
+	chk.CreatedByUser = userId
+	chk.CreatedByChat = chatId
+	chk.CreatedByMessage = msgId
+	if err = chk.validate(); err != nil {
+		return check{}, err
+	}
+	times := make([]time.Time, len(this.Attempts))
+	for i, impAtt := range this.Attempts {
+		if times[i], err = parseImportTime(impAtt.CreatedAt, now.Location()); err != nil {
+			return check{}, fmt.Errorf("attempt %d: %w", i+1, err)
+		}
+	}
+	if chk.CreatedAt.IsZero() {
+		// undated check precedes its dated attempts, and its undated attempts end by now
+		chk.CreatedAt = now.Add(-time.Duration(len(times)) * time.Second)
+		for _, t := range times {
+			if !t.IsZero() && t.Before(chk.CreatedAt) {
+				chk.CreatedAt = t
+			}
+		}
+	}
+	// dated is the last time from the file, prev is the last time given to an attempt
+	dated, datedIndex := chk.CreatedAt, 0
+	prev := chk.CreatedAt
+	for i, impAtt := range this.Attempts {
+		if chk.closed() {
+			return check{}, fmt.Errorf("attempt %d follows closing attempt", i+1)
+		}
+		att := attempt{
+			CreatedAt:        times[i],
+			CreatedByUser:    userId,
+			CreatedByChat:    chatId,
+			CreatedByMessage: msgId,
+		}
+		if att.Result, err = impAtt.Result.resolve("result.", resultNames[:], resultLabels[:]); err != nil {
+			return check{}, fmt.Errorf("attempt %d result: %w", i+1, err)
+		}
+		if !att.CreatedAt.IsZero() {
+			if att.CreatedAt.Before(dated) && datedIndex == 0 {
+				return check{}, fmt.Errorf("attempt %d is dated before the check", i+1)
+			} else if att.CreatedAt.Before(dated) {
+				return check{}, fmt.Errorf("attempt %d is dated before attempt %d", i+1, datedIndex)
+			}
+			dated, datedIndex = att.CreatedAt, i+1
+		}
+		if !att.CreatedAt.After(prev) {
+			att.CreatedAt = prev.Add(time.Second)
+		}
+		prev = att.CreatedAt
+		if err = att.validate(); err != nil {
+			return check{}, fmt.Errorf("attempt %d: %w", i+1, err)
+		}
+		chk.Attempts = append(chk.Attempts, att)
+	}
+	return chk, nil
+}
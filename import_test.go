@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseImportFile(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tooMany := "type,skill,difficulty,description\n" + strings.Repeat("red,logic,easy,x\n", maxImportChecks+1)
+	tests := []struct {
+		name    string
+		content string
+		want    []check
+		wantErr string
+	}{
+		{
+			name:    "json export",
+			content: `[{"type":"White check","skill":"🟦 Logic","difficulty":"Easy","description":" Solve it ","created_at":"2024-05-01 10:00","attempts":[{"result":"Failure 🔴"},{"result":3,"created_at":"2024-05-02"}]}]`,
+			want: []check{{Typ: typRetriable, Skill: intLogic, Difficulty: difEasy, Description: "Solve it",
+				CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+				Attempts: []attempt{
+					{Result: resFailure, CreatedAt: time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC)},
+					{Result: resSuccess, CreatedAt: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)},
+				}}},
+		},
+		{
+			name:    "json wrapped into object",
+			content: `{"checks":[{"type":1,"skill":2,"difficulty":3,"description":"d"}]}`,
+			want:    []check{{Typ: typNonRetriable, Skill: intEncyclopedia, Difficulty: difMedium, Description: "d", CreatedAt: now}},
+		},
+		{
+			name:    "csv with results",
+			content: "Type,Skill,Difficulty,Description,Created At,Results\nwhite,Half Light,Godly,Run,2.01.2006,Failure 🔴 | success\n\n",
+			want: []check{{Typ: typRetriable, Skill: phyHalflight, Difficulty: difGodly, Description: "Run",
+				CreatedAt: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC),
+				Attempts: []attempt{
+					{Result: resFailure, CreatedAt: time.Date(2006, 1, 2, 0, 0, 1, 0, time.UTC)},
+					{Result: resSuccess, CreatedAt: time.Date(2006, 1, 2, 0, 0, 2, 0, time.UTC)},
+				}}},
+		},
+		{
+			name:    "csv with semicolons and russian names",
+			content: "type;skill;difficulty;description;results\nБелая проверка;🟦 Логика;Тривиально;Бег;Успех 🟢\n",
+			want: []check{{Typ: typRetriable, Skill: intLogic, Difficulty: difTrivial, Description: "Бег",
+				CreatedAt: now.Add(-time.Second),
+				Attempts:  []attempt{{Result: resSuccess, CreatedAt: now}}}},
+		},
+		{
+			name:    "undated check before dated attempts of the same time",
+			content: `[{"type":"white","skill":1,"difficulty":1,"description":"x","attempts":[{"result":"failure","created_at":"2024-05-02"},{"result":"failure","created_at":"2024-05-02"},{"result":"success"}]}]`,
+			want: []check{{Typ: typRetriable, Skill: intLogic, Difficulty: difTrivial, Description: "x",
+				CreatedAt: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+				Attempts: []attempt{
+					{Result: resFailure, CreatedAt: time.Date(2024, 5, 2, 0, 0, 1, 0, time.UTC)},
+					{Result: resFailure, CreatedAt: time.Date(2024, 5, 2, 0, 0, 2, 0, time.UTC)},
+					{Result: resSuccess, CreatedAt: time.Date(2024, 5, 2, 0, 0, 3, 0, time.UTC)},
+				}}},
+		},
+		{name: "empty file", content: " \n ", wantErr: "file is empty"},
+		{name: "broken json", content: `[{"type":`, wantErr: "unexpected end of JSON input"},
+		{name: "no checks", content: `[]`, wantErr: "file contains no checks"},
+		{name: "missing column", content: "type,skill,description\nred,logic,x\n", wantErr: `column "difficulty" is missing`},
+		{name: "unknown skill", content: "type,skill,difficulty,description\nred,Juggling,easy,x\n", wantErr: `check 1: skill: unknown value "Juggling"`},
+		{name: "invalid difficulty id", content: `[{"type":1,"skill":1,"difficulty":42,"description":"x"}]`, wantErr: "invalid difficulty 42"},
+		{name: "unknown time format", content: `[{"type":1,"skill":1,"difficulty":1,"description":"x","created_at":"yesterday"}]`, wantErr: `unknown time format "yesterday"`},
+		{name: "missed result", content: "type,skill,difficulty,description,results\nred,logic,easy,x,missed\n", wantErr: "attempt 1: invalid result 4"},
+		{name: "attempt after closing one", content: "type,skill,difficulty,description,results\nred,logic,easy,x,failure success\n", wantErr: "attempt 2 follows closing attempt"},
+		{
+			name:    "attempt before check",
+			content: `[{"type":1,"skill":1,"difficulty":1,"description":"x","created_at":"2024-05-02","attempts":[{"result":"success","created_at":"2024-05-01"}]}]`,
+			wantErr: "attempt 1 is dated before the check",
+		},
+		{
+			name:    "attempts out of order",
+			content: `[{"type":"white","skill":1,"difficulty":1,"description":"x","attempts":[{"result":"failure","created_at":"2024-05-02"},{"result":"success","created_at":"2024-05-01"}]}]`,
+			wantErr: "attempt 2 is dated before attempt 1",
+		},
+		{name: "too long description", content: "type,skill,difficulty,description\nred,logic,easy," + strings.Repeat("x", maxDescriptionLength+1) + "\n", wantErr: "description is longer"},
+		{name: "too many checks", content: tooMany, wantErr: fmt.Sprintf("file contains %d checks", maxImportChecks+1)},
+		{name: "too large file", content: strings.Repeat(" ", int(maxImportFileSize)+1), wantErr: "file is larger than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseImportFile([]byte(tt.content), now, 1, 2, 3)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d checks, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				chk := got[i]
+				if chk.Typ != want.Typ || chk.Skill != want.Skill || chk.Difficulty != want.Difficulty ||
+					chk.Description != want.Description || !chk.CreatedAt.Equal(want.CreatedAt) {
+					t.Errorf("check %d = %+v, want %+v", i, chk, want)
+				}
+				if chk.CreatedByUser != 1 || chk.CreatedByChat != 2 || chk.CreatedByMessage != 3 {
+					t.Errorf("check %d metadata is not taken from the message", i)
+				}
+				if len(chk.Attempts) != len(want.Attempts) {
+					t.Fatalf("check %d has %d attempts, want %d", i, len(chk.Attempts), len(want.Attempts))
+				}
+				for j, att := range want.Attempts {
+					if chk.Attempts[j].Result != att.Result || !chk.Attempts[j].CreatedAt.Equal(att.CreatedAt) {
+						t.Errorf("check %d attempt %d = %+v, want %+v", i, j, chk.Attempts[j], att)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"discocheckbot/i18n"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// layouts of one date format
+type dateLayout struct {
+	date         string
+	dateTime     string
+	dayMonth     string
+	dayMonthTime string
+}
+
+// layouts by date format names stored for users
+var dateLayouts = map[string]dateLayout{
+	"dmy": {"2.01.2006", "2.01.2006 15:04", "2.01", "2.01 15:04"},
+	"mdy": {"1/2/2006", "1/2/2006 3:04 PM", "1/2", "1/2 3:04 PM"},
+	"ymd": {"2006-01-02", "2006-01-02 15:04", "01-02", "01-02 15:04"},
+}
+
+// texts of the user's language, with names of the game entities,
+// and dates in the user's time zone and format
+type locale struct {
+	*i18n.Localizer
+	zone   *time.Location
+	layout dateLayout
+}
+
+// server time zone and the first date format until user chooses others
+func newLocale(tag string) locale {
+	return locale{i18n.Default.Localizer(tag), time.Local, dateLayouts[dateFormats[0]]}
+}
+
+// unknown time zone is server one, so broken settings do not break messages
+func loadZone(timezone string) *time.Location {
+	if zone, err := time.LoadLocation(timezone); err == nil && timezone != "" {
+		return zone
+	}
+	return time.Local
+}
+
+// unknown date format is ignored, so broken settings do not break messages
+func (this locale) withDates(zone *time.Location, format string) locale {
+	this.zone = zone
+	if layout, ok := dateLayouts[format]; ok {
+		this.layout = layout
+	}
+	return this
+}
+
+func (this locale) now() time.Time {
+	return time.Now().In(this.zone)
+}
+
+func (this locale) date(t time.Time) string {
+	return t.In(this.zone).Format(this.layout.date)
+}
+
+func (this locale) dateTime(t time.Time) string {
+	return t.In(this.zone).Format(this.layout.dateTime)
+}
+
+func (this locale) dayMonth(t time.Time) string {
+	return t.In(this.zone).Format(this.layout.dayMonth)
+}
+
+func (this locale) dayMonthTime(t time.Time) string {
+	return t.In(this.zone).Format(this.layout.dayMonthTime)
+}
+
+// name of the zone with its current offset, e.g. "Europe/Berlin (UTC+02:00)"
+func (this locale) zoneName() string {
+	name := this.zone.String()
+	if this.zone == time.Local {
+		name = this.T("timezone.server")
+	}
+	return name + " (UTC" + this.now().Format("-07:00") + ")"
+}
+
+func (this locale) skill(skill int) string {
+	return this.T("skill." + strconv.Itoa(skill))
+}
+
+// skill name without its color mark, in capitals, as the skill speaks in the game
+func (this locale) skillVoice(skill int) string {
+	name := this.skill(skill)
+	if _, plain, found := strings.Cut(name, " "); found {
+		name = plain
+	}
+	return strings.ToUpper(name)
+}
+
+func (this locale) difficulty(difficulty int) string {
+	return this.T("difficulty." + strconv.Itoa(difficulty))
+}
+
+func (this locale) result(result int) string {
+	return this.T("result." + strconv.Itoa(result))
+}
+
+func (this locale) typ(typ int) string {
+	return this.T("type." + strconv.Itoa(typ))
+}
+
+func (this locale) period(period int) string {
+	return this.T("period." + strconv.Itoa(period))
+}
+
+// short weekday name, e.g. "Mon"
+func (this locale) weekday(day time.Weekday) string {
+	return this.T("weekday." + strconv.Itoa(int(day)))
+}
+
+func (this locale) thoughtName(thought int) string {
+	return this.T("thought." + strconv.Itoa(thought) + ".name")
+}
+
+func (this locale) thoughtProblem(thought int) string {
+	return this.T("thought." + strconv.Itoa(thought) + ".problem")
+}
+
+// translated text of the error, errors which are not caused by user are shown as is
+func (this locale) error(err error) string {
+	var uerr userError
+	if !errors.As(err, &uerr) {
+		return err.Error()
+	}
+	args := make([]any, len(uerr.args))
+	for i, arg := range uerr.args {
+		if t, ok := arg.(time.Time); ok {
+			args[i] = this.dateTime(t)
+		} else {
+			args[i] = arg
+		}
+	}
+	return this.T(uerr.key, args...)
+}
+
+// error caused by user's input or action, explained in the user's language,
+// time arguments are shown in the user's time zone
+type userError struct {
+	key  string
+	args []any
+}
+
+func newUserError(key string, args ...any) error {
+	return userError{key, args}
+}
+
+// english text, for logs
+func (this userError) Error() string {
+	return newLocale(i18n.Fallback).error(this)
+}
+
+// fixed offset zone of the longitude, daylight saving time is unknown without zone borders,
+// e.g. 37.6 gives "Etc/GMT-3", which is UTC+3
+func zoneByLongitude(longitude float64) string {
+	offset := int(math.Round(longitude / 15))
+	if offset == 0 {
+		return "Etc/GMT"
+	}
+	return fmt.Sprintf("Etc/GMT%+d", -offset)
+}
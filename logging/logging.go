@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// formats of log records
+const (
+	TextFormat string = "text"
+	JSONFormat string = "json"
+)
+
+// keys of attributes shared by all components, so records of one update can be found together
+const (
+	ComponentKey string = "component"
+	UpdateIDKey  string = "update_id"
+	ChatIDKey    string = "chat_id"
+	UserIDKey    string = "user_id"
+	MessageIDKey string = "message_id"
+	CheckIDKey   string = "check_id"
+	// content written by users, e.g. text of message or inline query
+	TextKey  string = "text"
+	Redacted string = "[redacted]"
+)
+
+// New returns logger writing records of the format at the level or above,
+// level may be slog.LevelVar to change it later.
+// With redact, user content under TextKey is hidden. Secrets, e.g. the bot token,
+// are hidden anyway, wherever they appear: in messages, strings and errors.
+func New(w io.Writer, format string, level slog.Leveler, redact bool, secrets ...string) (*slog.Logger, error) {
+	var replacements []string
+	for _, secret := range secrets {
+		if secret != "" {
+			replacements = append(replacements, secret, Redacted)
+		}
+	}
+	hider := strings.NewReplacer(replacements...)
+	options := slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if redact && attr.Key == TextKey {
+				return slog.String(attr.Key, Redacted)
+			}
+			if len(replacements) == 0 {
+				return attr
+			}
+			switch attr.Value.Kind() {
+			case slog.KindString:
+				return slog.String(attr.Key, hider.Replace(attr.Value.String()))
+			case slog.KindAny:
+				if err, ok := attr.Value.Any().(error); ok {
+					return slog.String(attr.Key, hider.Replace(err.Error()))
+				}
+			}
+			return attr
+		},
+	}
+	switch format {
+	case TextFormat:
+		return slog.New(slog.NewTextHandler(w, &options)), nil
+	case JSONFormat:
+		return slog.New(slog.NewJSONHandler(w, &options)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, expected %q or %q", format, TextFormat, JSONFormat)
+}
+
+// ParseLevel reads level by name, e.g. "debug", "info", "warn" or "error"
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return level, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// Component returns logger of a part of the bot, e.g. "api" or "reminders"
+func Component(log *slog.Logger, name string) *slog.Logger {
+	return log.With(ComponentKey, name)
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	const token = "123456:secret-token"
+	tests := []struct {
+		name     string
+		format   string
+		redact   bool
+		wantText string
+	}{
+		{name: "json redacted", format: JSONFormat, redact: true, wantText: Redacted},
+		{name: "json with text", format: JSONFormat, redact: false, wantText: "hello bot"},
+		{name: "text redacted", format: TextFormat, redact: true, wantText: Redacted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			log, err := New(&out, tt.format, slog.LevelInfo, tt.redact, token, "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			log = Component(log, "api")
+			log.Debug("not written " + token)
+			log.Error("request to https://api.telegram.org/bot"+token+"/getUpdates failed",
+				"error", errors.New("post bot"+token+": timeout"),
+				"url", "https://api.telegram.org/bot"+token,
+				UserIDKey, 42,
+				TextKey, "hello bot",
+				slog.Group("update", TextKey, "hello bot"))
+			record := out.String()
+			if strings.Contains(record, token) {
+				t.Errorf("token is written: %s", record)
+			}
+			if strings.Contains(record, "not written") {
+				t.Errorf("record below the level is written: %s", record)
+			}
+			if tt.format == TextFormat {
+				if !strings.Contains(record, "text="+Redacted) || strings.Contains(record, "hello bot") {
+					t.Errorf("text is not redacted: %s", record)
+				}
+				return
+			}
+			var fields struct {
+				Msg       string
+				Error     string
+				Component string
+				UserID    int64 `json:"user_id"`
+				Text      string
+				Update    struct{ Text string }
+			}
+			if err := json.Unmarshal(out.Bytes(), &fields); err != nil {
+				t.Fatalf("record is not json: %v", err)
+			}
+			if fields.Msg != "request to https://api.telegram.org/bot"+Redacted+"/getUpdates failed" ||
+				fields.Error != "post bot"+Redacted+": timeout" {
+				t.Errorf("token is not replaced in message or error: %s", record)
+			}
+			if fields.Component != "api" || fields.UserID != 42 {
+				t.Errorf("attributes are changed: %s", record)
+			}
+			if fields.Text != tt.wantText || fields.Update.Text != tt.wantText {
+				t.Errorf("text = %q and %q, want %q", fields.Text, fields.Update.Text, tt.wantText)
+			}
+		})
+	}
+	if _, err := New(&bytes.Buffer{}, "xml", slog.LevelInfo, true); err == nil {
+		t.Error("unknown format is accepted")
+	}
+}
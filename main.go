@@ -3,26 +3,99 @@ package main
 import (
 	"discocheckbot/api"
 	"discocheckbot/config"
-	"log"
+	"discocheckbot/logging"
+	"discocheckbot/metrics"
+	"flag"
+	"log/slog"
+	"net/http"
 	"os"
+	_ "time/tzdata" // time zones of users do not depend on the system database
 )
 
 func main() {
-	log := log.New(os.Stdout, "", log.Ldate|log.Ltime)
-	log.Println("starting bot...")
+	// records before the config is loaded have no settings to follow
+	log := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-	config, err := config.NewConfigReader("./config.json")
+	configPath := flag.String("config", "./config.json",
+		"path to JSON config, empty to take parameters from "+config.EnvPrefix+"* environment variables only")
+	flag.Parse()
+	reader, err := config.NewConfigReader(*configPath, nil)
 	if err != nil {
-		log.Fatalln(err.Error())
+		fatal(log, "reading config failed", err)
 	}
-	dcbot, err := NewDiscoCheckBot(config)
+	var cfg config.Config
+	if err = reader.Load(&cfg); err != nil {
+		fatal(log, "invalid config", err)
+	}
+	level := new(slog.LevelVar)
+	if err = setLogLevel(level, &cfg); err != nil {
+		fatal(log, "invalid config", err)
+	}
+	log, err = logging.New(os.Stdout, cfg.LogFormat, level, cfg.LogRedact, cfg.BotToken, cfg.DbPassword)
+	if err != nil {
+		fatal(slog.Default(), "invalid config", err)
+	}
+	log.Info("starting bot")
+
+	dcbot, err := NewDiscoCheckBot(&cfg)
 	if err != nil {
-		log.Fatalln(err)
+		fatal(log, "connecting to database failed", err)
 	}
-	bot, err := api.NewBot(config, log, dcbot)
+	antiSpam, err := dcbot.AntiSpam(&cfg, logging.Component(log, "antispam"))
 	if err != nil {
-		log.Fatalln(err)
+		fatal(log, "loading blocks failed", err)
+	}
+	updatesLog := logging.Component(log, "updates")
+	bot, err := api.NewBot(&cfg, logging.Component(log, "api"), api.Chain(dcbot,
+		api.Logging(updatesLog),
+		api.Recover(updatesLog),
+		api.AccessList(cfg.AllowedIds, cfg.DeniedIds),
+		antiSpam))
+	if err != nil {
+		fatal(log, "connecting to telegram failed", err)
+	}
+	if err = dcbot.PublishCommands(bot); err != nil {
+		log.Error("publishing commands failed", "error", err)
+	}
+	configLog := logging.Component(log, "config")
+	reader.Subscribe(func(prev *config.Config, next *config.Config) {
+		if err := setLogLevel(level, next); err != nil {
+			configLog.Warn("log level is not changed", "error", err)
+		}
+	})
+	reader.Subscribe(bot.ApplyConfig)
+	reader.Subscribe(dcbot.ApplyConfig)
+	go reader.Watch(cfg, configPollInterval, configLog)
+	go dcbot.RunReminders(bot, logging.Component(log, "reminders"))
+	if cfg.HttpAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", metrics.Default)
+		mux.Handle("GET /healthz", dcbot.handleLiveness(bot))
+		mux.Handle("GET /readyz", dcbot.handleReadiness(bot))
+		go serveHttp(cfg.HttpAddress, mux, logging.Component(log, "http"))
 	}
 	bot.ListenForUpdates()
-	log.Fatalln("bot terminated")
+	log.Error("bot terminated")
+	os.Exit(1)
+}
+
+func setLogLevel(level *slog.LevelVar, cfg *config.Config) error {
+	parsed, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	level.Set(parsed)
+	return nil
+}
+
+// serves metrics and health checks, the bot goes on without them if the address is taken
+func serveHttp(address string, handler http.Handler, log *slog.Logger) {
+	server := http.Server{Addr: address, Handler: handler, ReadHeaderTimeout: httpReadTimeout}
+	log.Info("serving http", "address", address)
+	log.Error("serving http failed", "error", server.ListenAndServe())
+}
+
+func fatal(log *slog.Logger, msg string, err error) {
+	log.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -1,72 +1,75 @@
 package main
 
 import (
+	"cmp"
 	"discocheckbot/api"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf16"
 )
 
-func getSkillMessage(cmd string, chatId int64, color int) api.SendMessage {
+func getSkillMessage(lc locale, cmd string, chatId int64, color int) api.SendMessage {
 	chkColor := int64(color)
 	smsg := api.SendMessage{
 		ChatID: chatId,
-		Text:   "Select skill:",
+		Text:   lc.T("check.select_skill"),
 		ReplyMarkup: &api.InlineKeyboardMarkup{
 			InlineKeyboard: [][]api.InlineKeyboardButton{
-				{{Text: skillNames[intLogic], CallbackData: makeClbk(cmd, chkColor, intLogic)},
-					{Text: skillNames[intEncyclopedia], CallbackData: makeClbk(cmd, chkColor, intEncyclopedia)}},
-				{{Text: skillNames[intRhetoric], CallbackData: makeClbk(cmd, chkColor, intRhetoric)},
-					{Text: skillNames[intDrama], CallbackData: makeClbk(cmd, chkColor, intDrama)}},
-				{{Text: skillNames[intConcept], CallbackData: makeClbk(cmd, chkColor, intConcept)},
-					{Text: skillNames[intVisual], CallbackData: makeClbk(cmd, chkColor, intEncyclopedia)}},
-				{{Text: skillNames[psyVolition], CallbackData: makeClbk(cmd, chkColor, psyVolition)},
-					{Text: skillNames[psyInland], CallbackData: makeClbk(cmd, chkColor, psyInland)}},
-				{{Text: skillNames[psyEmpathy], CallbackData: makeClbk(cmd, chkColor, psyEmpathy)},
-					{Text: skillNames[psyAuthority], CallbackData: makeClbk(cmd, chkColor, psyAuthority)}},
-				{{Text: skillNames[psyEsprit], CallbackData: makeClbk(cmd, chkColor, psyEsprit)},
-					{Text: skillNames[psySuggestion], CallbackData: makeClbk(cmd, chkColor, psySuggestion)}},
-				{{Text: skillNames[phyEndurance], CallbackData: makeClbk(cmd, chkColor, phyEndurance)},
-					{Text: skillNames[phyPain], CallbackData: makeClbk(cmd, chkColor, phyPain)}},
-				{{Text: skillNames[phyInstrument], CallbackData: makeClbk(cmd, chkColor, phyInstrument)},
-					{Text: skillNames[phyElectrochem], CallbackData: makeClbk(cmd, chkColor, phyElectrochem)}},
-				{{Text: skillNames[phyShivers], CallbackData: makeClbk(cmd, chkColor, phyShivers)},
-					{Text: skillNames[phyHalflight], CallbackData: makeClbk(cmd, chkColor, phyHalflight)}},
-				{{Text: skillNames[motCoordintation], CallbackData: makeClbk(cmd, chkColor, motCoordintation)},
-					{Text: skillNames[motPerception], CallbackData: makeClbk(cmd, chkColor, motPerception)}},
-				{{Text: skillNames[motReaction], CallbackData: makeClbk(cmd, chkColor, motReaction)},
-					{Text: skillNames[motSavoir], CallbackData: makeClbk(cmd, chkColor, motSavoir)}},
-				{{Text: skillNames[motInterfacing], CallbackData: makeClbk(cmd, chkColor, motInterfacing)},
-					{Text: skillNames[motComposure], CallbackData: makeClbk(cmd, chkColor, motComposure)}},
+				{{Text: lc.skill(intLogic), CallbackData: makeClbk(cmd, chkColor, intLogic)},
+					{Text: lc.skill(intEncyclopedia), CallbackData: makeClbk(cmd, chkColor, intEncyclopedia)}},
+				{{Text: lc.skill(intRhetoric), CallbackData: makeClbk(cmd, chkColor, intRhetoric)},
+					{Text: lc.skill(intDrama), CallbackData: makeClbk(cmd, chkColor, intDrama)}},
+				{{Text: lc.skill(intConcept), CallbackData: makeClbk(cmd, chkColor, intConcept)},
+					{Text: lc.skill(intVisual), CallbackData: makeClbk(cmd, chkColor, intEncyclopedia)}},
+				{{Text: lc.skill(psyVolition), CallbackData: makeClbk(cmd, chkColor, psyVolition)},
+					{Text: lc.skill(psyInland), CallbackData: makeClbk(cmd, chkColor, psyInland)}},
+				{{Text: lc.skill(psyEmpathy), CallbackData: makeClbk(cmd, chkColor, psyEmpathy)},
+					{Text: lc.skill(psyAuthority), CallbackData: makeClbk(cmd, chkColor, psyAuthority)}},
+				{{Text: lc.skill(psyEsprit), CallbackData: makeClbk(cmd, chkColor, psyEsprit)},
+					{Text: lc.skill(psySuggestion), CallbackData: makeClbk(cmd, chkColor, psySuggestion)}},
+				{{Text: lc.skill(phyEndurance), CallbackData: makeClbk(cmd, chkColor, phyEndurance)},
+					{Text: lc.skill(phyPain), CallbackData: makeClbk(cmd, chkColor, phyPain)}},
+				{{Text: lc.skill(phyInstrument), CallbackData: makeClbk(cmd, chkColor, phyInstrument)},
+					{Text: lc.skill(phyElectrochem), CallbackData: makeClbk(cmd, chkColor, phyElectrochem)}},
+				{{Text: lc.skill(phyShivers), CallbackData: makeClbk(cmd, chkColor, phyShivers)},
+					{Text: lc.skill(phyHalflight), CallbackData: makeClbk(cmd, chkColor, phyHalflight)}},
+				{{Text: lc.skill(motCoordintation), CallbackData: makeClbk(cmd, chkColor, motCoordintation)},
+					{Text: lc.skill(motPerception), CallbackData: makeClbk(cmd, chkColor, motPerception)}},
+				{{Text: lc.skill(motReaction), CallbackData: makeClbk(cmd, chkColor, motReaction)},
+					{Text: lc.skill(motSavoir), CallbackData: makeClbk(cmd, chkColor, motSavoir)}},
+				{{Text: lc.skill(motInterfacing), CallbackData: makeClbk(cmd, chkColor, motInterfacing)},
+					{Text: lc.skill(motComposure), CallbackData: makeClbk(cmd, chkColor, motComposure)}},
 			},
 		},
 	}
 	return smsg
 }
 
-func getSkillDifEditMessage(chatId int64, msgId int, clbk string) api.EditMessageText {
+func getSkillDifEditMessage(lc locale, chatId int64, msgId int, clbk string) api.EditMessageText {
 	emsg := api.EditMessageText{
 		ChatID:    chatId,
 		MessageID: msgId,
-		Text:      "Select check difficulty:",
+		Text:      lc.T("check.select_difficulty"),
 		ReplyMarkup: &api.InlineKeyboardMarkup{
 			InlineKeyboard: [][]api.InlineKeyboardButton{
-				{{Text: difficultyNames[difTrivial], CallbackData: makeClbk(clbk, difTrivial)}},
-				{{Text: difficultyNames[difEasy], CallbackData: makeClbk(clbk, difEasy)}},
-				{{Text: difficultyNames[difMedium], CallbackData: makeClbk(clbk, difMedium)}},
-				{{Text: difficultyNames[difChallenging], CallbackData: makeClbk(clbk, difChallenging)}},
-				{{Text: difficultyNames[difFormidable], CallbackData: makeClbk(clbk, difFormidable)}},
-				{{Text: difficultyNames[difLegendary], CallbackData: makeClbk(clbk, difLegendary)}},
-				{{Text: difficultyNames[difHeroic], CallbackData: makeClbk(clbk, difHeroic)}},
-				{{Text: difficultyNames[difGodly], CallbackData: makeClbk(clbk, difGodly)}},
-				{{Text: difficultyNames[difImpossible], CallbackData: makeClbk(clbk, difImpossible)}},
+				{{Text: lc.difficulty(difTrivial), CallbackData: makeClbk(clbk, difTrivial)}},
+				{{Text: lc.difficulty(difEasy), CallbackData: makeClbk(clbk, difEasy)}},
+				{{Text: lc.difficulty(difMedium), CallbackData: makeClbk(clbk, difMedium)}},
+				{{Text: lc.difficulty(difChallenging), CallbackData: makeClbk(clbk, difChallenging)}},
+				{{Text: lc.difficulty(difFormidable), CallbackData: makeClbk(clbk, difFormidable)}},
+				{{Text: lc.difficulty(difLegendary), CallbackData: makeClbk(clbk, difLegendary)}},
+				{{Text: lc.difficulty(difHeroic), CallbackData: makeClbk(clbk, difHeroic)}},
+				{{Text: lc.difficulty(difGodly), CallbackData: makeClbk(clbk, difGodly)}},
+				{{Text: lc.difficulty(difImpossible), CallbackData: makeClbk(clbk, difImpossible)}},
 			},
 		},
 	}
 	return emsg
 }
 
-func getListCheckMessage(cmd string, chatId int64, list []check) api.SendMessage {
+func getListCheckMessage(lc locale, cmd string, chatId int64, list []check) api.SendMessage {
 	var btnList [][]api.InlineKeyboardButton
 	var btnRow []api.InlineKeyboardButton
 	var markup *api.InlineKeyboardMarkup
@@ -80,13 +83,13 @@ func getListCheckMessage(cmd string, chatId int64, list []check) api.SendMessage
 			nextId = list[len(list)-1].Id
 		}
 		btnRow = []api.InlineKeyboardButton{
-			{Text: "⬅️ Newer", CallbackData: makeClbk(cmd, listCheckBackward, 0)},
-			{Text: "Older ➡️", CallbackData: makeClbk(cmd, listCheckForward, nextId)},
+			{Text: lc.T("list.newer"), CallbackData: makeClbk(cmd, listCheckBackward, 0)},
+			{Text: lc.T("list.older"), CallbackData: makeClbk(cmd, listCheckForward, nextId)},
 		}
 		btnList = append(btnList, btnRow)
 		btnRow = nil
 	} else {
-		msgText.sb.WriteString("You have no checks at the moment")
+		msgText.sb.WriteString(lc.T("list.empty"))
 	}
 	for i, chk := range list {
 		crossBegin := 0
@@ -103,9 +106,13 @@ func getListCheckMessage(cmd string, chatId int64, list []check) api.SendMessage
 				crossBegin--
 			}
 		}
-		msgText.concat(strconv.Itoa(i+1), ". ", typeNames[chk.Typ], sep, resultNames[res], "\n")
+		msgText.concat(strconv.Itoa(i+1), ". ", getCheckTypeName(lc, chk), sep, lc.result(res))
+		if !chk.closed() && !chk.DueAt.IsZero() {
+			msgText.concat("⏰ ", lc.dayMonthTime(chk.DueAt))
+		}
+		msgText.sb.WriteString("\n")
 		boldBegin = len(utf16.Encode([]rune(msgText.sb.String()))) - 1
-		msgText.concat(skillNames[chk.Skill], " - ", difficultyNames[chk.Difficulty], "\n")
+		msgText.concat(lc.skill(chk.Skill), " - ", lc.difficulty(chk.Difficulty), "\n")
 		boldEnd = len(utf16.Encode([]rune(msgText.sb.String()))) - 1
 		msgText.sb.WriteString(chk.Description)
 		if chk.closed() {
@@ -142,13 +149,24 @@ func getListCheckMessage(cmd string, chatId int64, list []check) api.SendMessage
 	return smsg
 }
 
-func getListCheckEditMessage(chatId int64, msgId int, list []check) api.EditMessageText {
-	baseMsg := getListCheckMessage(seeTop, chatId, list)
+// attempt just made is offered to undo and to add a note to it, 0 ids for none
+func getListCheckEditMessage(lc locale, chatId int64, msgId int, list []check, undoAttemptId int64, noteAttemptId int64) api.EditMessageText {
+	baseMsg := getListCheckMessage(lc, seeTop, chatId, list)
 	var prevId, nextId int64
 	prevId = list[0].Id
 	nextId = list[len(list)-1].Id
 	baseMsg.ReplyMarkup.InlineKeyboard[0][0].CallbackData = makeClbk(seeTop, listCheckBackward, prevId)
 	baseMsg.ReplyMarkup.InlineKeyboard[0][1].CallbackData = makeClbk(seeTop, listCheckForward, nextId)
+	var btnRow []api.InlineKeyboardButton
+	if undoAttemptId != 0 {
+		btnRow = append(btnRow, api.InlineKeyboardButton{Text: lc.T("attempt.undo"), CallbackData: makeClbk(seeTop, listCheckUndo, undoAttemptId)})
+	}
+	if noteAttemptId != 0 {
+		btnRow = append(btnRow, api.InlineKeyboardButton{Text: lc.T("attempt.note_add"), CallbackData: makeClbk(seeTop, listCheckNote, noteAttemptId)})
+	}
+	if len(btnRow) > 0 {
+		baseMsg.ReplyMarkup.InlineKeyboard = append(baseMsg.ReplyMarkup.InlineKeyboard, btnRow)
+	}
 
 	emsg := api.EditMessageText{
 		ChatID:      chatId,
@@ -160,64 +178,142 @@ func getListCheckEditMessage(chatId int64, msgId int, list []check) api.EditMess
 	return emsg
 }
 
-func getSingleCheckEditMessage(chatId int64, msgId int, chk check) api.EditMessageText {
+func getSingleCheckEditMessage(lc locale, chatId int64, msgId int, chk check) api.EditMessageText {
 	var msgText myStringsBuilder
-	msgText.concat(typeNames[chk.Typ], ":\n")
+	msgText.concat(getCheckTypeName(lc, chk), ":\n")
 	boldBegin := len(utf16.Encode([]rune(msgText.sb.String()))) - 1
-	msgText.concat(skillNames[chk.Skill], " - ", difficultyNames[chk.Difficulty], "\n")
+	msgText.concat(lc.skill(chk.Skill))
+	if chk.Modifier != 0 {
+		msgText.concat(" ", formatModifier(chk.Modifier))
+	}
+	msgText.concat(" - ", lc.difficulty(chk.Difficulty), "\n")
 	boldEnd := len(utf16.Encode([]rune(msgText.sb.String()))) - 1
 	msgText.concat(chk.Description, "\n\n")
+	writeCheckSchedule(lc, &msgText, chk)
 
 	emsg := api.EditMessageText{
 		ChatID:    chatId,
 		MessageID: msgId,
 		Entities:  []api.MessageEntity{{Type: api.BoldEntity, Offset: boldBegin, Length: boldEnd - boldBegin}},
 	}
-	for _, attempt := range chk.Attempts {
-		msgText.concat("Attempt at: ", attempt.CreatedAt.Format("2.01.2006 15:04"), "\nResult: ",
-			resultNames[attempt.Result], "\n")
+	var photoRow []api.InlineKeyboardButton
+	for i, attempt := range chk.Attempts {
+		msgText.concat(lc.T("check.attempt", lc.dateTime(attempt.CreatedAt), lc.result(attempt.Result)), "\n")
+		if attempt.Note != "" {
+			msgText.concat("📝 ", attempt.Note, "\n")
+		}
+		if attempt.PhotoId != "" {
+			msgText.concat(lc.T("attempt.photo", i+1), "\n")
+			photoRow = append(photoRow, api.InlineKeyboardButton{
+				Text:         lc.T("attempt.photo", i+1),
+				CallbackData: makeClbk(seeTop, listCheckPhoto, attempt.Id)})
+		}
 	}
 	emsg.Text = msgText.sb.String()
+	var btnList [][]api.InlineKeyboardButton
 	if !chk.closed() {
-		emsg.ReplyMarkup = &api.InlineKeyboardMarkup{
-			InlineKeyboard: [][]api.InlineKeyboardButton{
-				{{Text: resultNames[resSuccess], CallbackData: makeClbk(seeTop, listCheckAction, chk.Id, resSuccess)}},
-				{{Text: resultNames[resFailure], CallbackData: makeClbk(seeTop, listCheckAction, chk.Id, resFailure)}},
-				{{Text: resultNames[resCanceled], CallbackData: makeClbk(seeTop, listCheckAction, chk.Id, resCanceled)}},
-				{{Text: "Back", CallbackData: makeClbk(seeTop, listCheckForward, 0)}},
-			},
+		btnList = [][]api.InlineKeyboardButton{
+			{{Text: lc.result(resSuccess), CallbackData: makeClbk(seeTop, listCheckAction, chk.Id, resSuccess)}},
+			{{Text: lc.result(resFailure), CallbackData: makeClbk(seeTop, listCheckAction, chk.Id, resFailure)}},
+			{{Text: lc.result(resCanceled), CallbackData: makeClbk(seeTop, listCheckAction, chk.Id, resCanceled)}},
 		}
-	} else {
-		emsg.ReplyMarkup = &api.InlineKeyboardMarkup{
-			InlineKeyboard: [][]api.InlineKeyboardButton{
-				{{Text: "Back", CallbackData: makeClbk(seeTop, listCheckForward, 0)}},
-			},
+	}
+	if len(photoRow) > maxCheckBtnInRow {
+		photoRow = photoRow[len(photoRow)-maxCheckBtnInRow:]
+	}
+	if len(photoRow) > 0 {
+		btnList = append(btnList, photoRow)
+	}
+	if last, ok := chk.lastAttempt(); ok {
+		var btnRow []api.InlineKeyboardButton
+		if chk.CanUndo {
+			btnRow = append(btnRow, api.InlineKeyboardButton{Text: lc.T("attempt.undo"), CallbackData: makeClbk(seeTop, listCheckUndo, last.Id)})
+		}
+		if chk.CanNote {
+			btnRow = append(btnRow, api.InlineKeyboardButton{Text: lc.T("attempt.note_add"), CallbackData: makeClbk(seeTop, listCheckNote, last.Id)})
 		}
+		if len(btnRow) > 0 {
+			btnList = append(btnList, btnRow)
+		}
+		// results other than the current one, as corrections of the last attempt
+		if chk.CanChange {
+			var btnRow []api.InlineKeyboardButton
+			for _, result := range []int{resSuccess, resFailure, resCanceled} {
+				if result != last.Result {
+					btnRow = append(btnRow, api.InlineKeyboardButton{
+						Text:         lc.T("attempt.change", lc.result(result)),
+						CallbackData: makeClbk(seeTop, listCheckChange, last.Id, int64(result))})
+				}
+			}
+			btnList = append(btnList, btnRow)
+		}
+	}
+	if chk.CanSave {
+		btnList = append(btnList, []api.InlineKeyboardButton{
+			{Text: lc.T("template.save"), CallbackData: makeClbk(seeTop, listCheckTemplate, chk.Id)}})
 	}
+	btnList = append(btnList, []api.InlineKeyboardButton{
+		{Text: lc.T("button.back"), CallbackData: makeClbk(seeTop, listCheckForward, 0)}})
+	emsg.ReplyMarkup = &api.InlineKeyboardMarkup{InlineKeyboard: btnList}
 	return emsg
 }
 
-func getSingleCheckMessage(chatId int64, chk check) api.SendMessage {
+// check opened with start link, owner may attempt it right here, others may only copy it
+func getLinkedCheckMessage(lc locale, chatId int64, chk check, own bool, cloneLink string) api.SendMessage {
+	if own {
+		return getCheckCardMessage(lc, chatId, chk)
+	}
+	smsg := getSingleCheckMessage(lc, chatId, chk, "")
+	smsg.ReplyMarkup = &api.InlineKeyboardMarkup{
+		InlineKeyboard: [][]api.InlineKeyboardButton{{{Text: lc.T("link.clone"), URL: cloneLink}}},
+	}
+	return smsg
+}
+
+// the same card as in the list of checks, but sent as a new message
+func getCheckCardMessage(lc locale, chatId int64, chk check) api.SendMessage {
+	emsg := getSingleCheckEditMessage(lc, chatId, 0, chk)
+	smsg := api.SendMessage{
+		ChatID:      chatId,
+		Text:        emsg.Text,
+		Entities:    emsg.Entities,
+		ReplyMarkup: emsg.ReplyMarkup,
+	}
+	return smsg
+}
+
+// voice is appended to the card, if skill has something to say
+func getSingleCheckMessage(lc locale, chatId int64, chk check, voice string) api.SendMessage {
 	var msgText myStringsBuilder
-	msgText.concat(typeNames[chk.Typ], ":\n")
+	msgText.concat(getCheckTypeName(lc, chk), ":\n")
 	boldBegin := len(utf16.Encode([]rune(msgText.sb.String()))) - 1
-	msgText.concat(skillNames[chk.Skill], " - ", difficultyNames[chk.Difficulty], "\n")
+	msgText.concat(lc.skill(chk.Skill), " - ", lc.difficulty(chk.Difficulty), "\n")
 	boldEnd := len(utf16.Encode([]rune(msgText.sb.String()))) - 1
-	msgText.concat(chk.Description, "\n\nCreated at: ", chk.CreatedAt.Format("2.01.2006 15:04"))
+	msgText.concat(chk.Description, "\n\n", lc.T("check.created_at", lc.dateTime(chk.CreatedAt)), "\n")
+	writeCheckSchedule(lc, &msgText, chk)
+	format := []api.MessageEntity{{Type: api.BoldEntity, Offset: boldBegin, Length: boldEnd - boldBegin}}
+	if voice != "" {
+		msgText.sb.WriteString("\n")
+		italicBegin := len(utf16.Encode([]rune(msgText.sb.String())))
+		msgText.sb.WriteString(voice)
+		italicEnd := len(utf16.Encode([]rune(msgText.sb.String())))
+		format = append(format, api.MessageEntity{Type: api.ItalicEntity, Offset: italicBegin, Length: italicEnd - italicBegin})
+	}
 	smsg := api.SendMessage{
 		ChatID:   chatId,
 		Text:     msgText.sb.String(),
-		Entities: []api.MessageEntity{{Type: api.BoldEntity, Offset: boldBegin, Length: boldEnd - boldBegin}},
+		Entities: format,
 	}
 	return smsg
 }
 
-func getSkillTxtEditMessage(chatId int64, msgId int, chk check) api.EditMessageText {
+func getSkillTxtEditMessage(lc locale, chatId int64, msgId int, chk check, skillDescr string) api.EditMessageText {
 	var msgText myStringsBuilder
-	msgText.concat("Enter description of the check:\n")
+	msgText.concat(lc.T("check.enter_description"), "\n")
 	boldBegin := len(utf16.Encode([]rune(msgText.sb.String()))) - 1
-	msgText.concat(skillNames[chk.Skill], " - ", difficultyNames[chk.Difficulty], "\n")
+	msgText.concat(lc.skill(chk.Skill), " - ", lc.difficulty(chk.Difficulty), "\n")
 	boldEnd := len(utf16.Encode([]rune(msgText.sb.String()))) - 1
+	msgText.sb.WriteString(skillDescr)
 	emsg := api.EditMessageText{
 		ChatID:    chatId,
 		MessageID: msgId,
@@ -227,26 +323,707 @@ func getSkillTxtEditMessage(chatId int64, msgId int, chk check) api.EditMessageT
 	return emsg
 }
 
-func getErrorMessage(chatId int64, err error) api.SendMessage {
+func getDueDateMessage(lc locale, chatId int64, now time.Time) api.SendMessage {
+	btnList := [][]api.InlineKeyboardButton{
+		{{Text: lc.T("due.none"), CallbackData: makeClbk(setDue, dueSkip, 0)}},
+	}
+	var btnRow []api.InlineKeyboardButton
+	for i := 0; i < maxDueDaysInPicker; i++ {
+		day := now.AddDate(0, 0, i)
+		var text string
+		switch i {
+		case 0:
+			text = lc.T("due.today")
+		case 1:
+			text = lc.T("due.tomorrow")
+		default:
+			text = lc.weekday(day.Weekday()) + " " + lc.dayMonth(day)
+		}
+		date, _ := strconv.ParseInt(day.Format(dueDateLayout), 10, 64)
+		btnRow = append(btnRow, api.InlineKeyboardButton{
+			Text:         text,
+			CallbackData: makeClbk(setDue, duePick, date),
+		})
+		if (i+1)%maxCheckBtnInRow == 0 || i+1 == maxDueDaysInPicker {
+			btnList = append(btnList, btnRow)
+			btnRow = nil
+		}
+	}
+	smsg := api.SendMessage{
+		ChatID:      chatId,
+		Text:        lc.T("due.prompt", defaultDueHour),
+		ReplyMarkup: &api.InlineKeyboardMarkup{InlineKeyboard: btnList},
+	}
+	return smsg
+}
+
+func getDueDateEditMessage(lc locale, chatId int64, msgId int, due time.Time) api.EditMessageText {
+	emsg := api.EditMessageText{
+		ChatID:    chatId,
+		MessageID: msgId,
+		Text:      lc.T("due.none"),
+	}
+	if !due.IsZero() {
+		emsg.Text = lc.T("due.deadline", lc.dateTime(due))
+	}
+	return emsg
+}
+
+func getReminderMessage(lc locale, chatId int64, chk check, kind int) api.SendMessage {
+	var msgText myStringsBuilder
+	switch {
+	case kind == remOverdue && chk.closed():
+		// recurring instance is closed as missed when its period ends
+		msgText.concat(lc.T("reminder.missed"), "\n")
+	case kind == remOverdue:
+		msgText.concat(lc.T("reminder.overdue"), "\n")
+	case kind == remRecur:
+		msgText.concat(lc.T("reminder.recur", lc.dateTime(chk.DueAt)), "\n")
+	default:
+		msgText.concat(lc.T("reminder.before_due", lc.dateTime(chk.DueAt)), "\n")
+	}
+	msgText.concat(getCheckTypeName(lc, chk), "\n")
+	boldBegin := len(utf16.Encode([]rune(msgText.sb.String()))) - 1
+	msgText.concat(lc.skill(chk.Skill), " - ", lc.difficulty(chk.Difficulty), "\n")
+	boldEnd := len(utf16.Encode([]rune(msgText.sb.String()))) - 1
+	msgText.concat(chk.Description)
+	if kind == remRecur {
+		msgText.concat("\n", lc.T("check.streak", chk.Streak))
+	}
+	smsg := api.SendMessage{
+		ChatID:   chatId,
+		Text:     strings.TrimSpace(msgText.sb.String()),
+		Entities: []api.MessageEntity{{Type: api.BoldEntity, Offset: boldBegin, Length: boldEnd - boldBegin}},
+	}
+	if !chk.closed() {
+		smsg.ReplyMarkup = &api.InlineKeyboardMarkup{
+			InlineKeyboard: [][]api.InlineKeyboardButton{
+				{{Text: lc.result(resSuccess), CallbackData: makeClbk(seeTop, listCheckAction, chk.Id, resSuccess)}},
+				{{Text: lc.result(resFailure), CallbackData: makeClbk(seeTop, listCheckAction, chk.Id, resFailure)}},
+				{{Text: lc.result(resCanceled), CallbackData: makeClbk(seeTop, listCheckAction, chk.Id, resCanceled)}},
+			},
+		}
+	}
+	return smsg
+}
+
+func getCabinetMessage(lc locale, chatId int64, userId int64, cabinet []cabinetThought) api.SendMessage {
+	var msgText myStringsBuilder
+	var btnList [][]api.InlineKeyboardButton
+	var format []api.MessageEntity
+	bySlot := make(map[int]cabinetThought)
+	for _, ct := range cabinet {
+		bySlot[ct.Slot] = ct
+	}
+	msgText.concat(lc.T("cabinet.title"), "\n\n")
+	for slot := 1; slot <= maxCabinetSlots; slot++ {
+		msgText.concat(lc.T("cabinet.slot", slot), " ")
+		ct, ok := bySlot[slot]
+		if !ok {
+			msgText.concat(lc.T("cabinet.slot_empty"), "\n\n")
+			btnList = append(btnList, []api.InlineKeyboardButton{{
+				Text:         lc.T("cabinet.internalize_into", slot),
+				CallbackData: makeClbk(seeCabinet, cabinetChoose, userId, int64(slot)),
+			}})
+			continue
+		}
+		def := ct.def()
+		boldBegin := len(utf16.Encode([]rune(msgText.sb.String())))
+		msgText.concat(lc.thoughtName(ct.Thought))
+		boldEnd := len(utf16.Encode([]rune(msgText.sb.String())))
+		format = append(format, api.MessageEntity{Type: api.BoldEntity, Offset: boldBegin, Length: boldEnd - boldBegin})
+		msgText.sb.WriteString("\n")
+		if ct.researching() {
+			if def.ResearchChecks > 0 {
+				msgText.concat(lc.N("cabinet.researching_checks", def.ResearchChecks,
+					min(ct.Progress, def.ResearchChecks)), "\n")
+			} else {
+				msgText.concat(lc.T("cabinet.researching_until",
+					lc.dateTime(ct.StartedAt.AddDate(0, 0, def.ResearchDays))), "\n")
+			}
+		} else {
+			msgText.concat(lc.T("cabinet.internalized_at", lc.date(ct.FinishedAt)), "\n")
+		}
+		msgText.concat(formatModifiers(lc, ct.modifiers()), "\n\n")
+		btnList = append(btnList, []api.InlineKeyboardButton{{
+			Text:         lc.T("cabinet.forget", lc.thoughtName(ct.Thought)),
+			CallbackData: makeClbk(seeCabinet, cabinetForget, userId, int64(slot)),
+		}})
+	}
+	var total []skillModifier
+	for skill, value := range cabinetModifiers(cabinet) {
+		if value != 0 {
+			total = append(total, skillModifier{skill, value})
+		}
+	}
+	if len(total) > 0 {
+		msgText.concat(lc.T("cabinet.total", formatModifiers(lc, total)))
+	} else {
+		msgText.concat(lc.T("cabinet.hint"))
+	}
+	smsg := api.SendMessage{
+		ChatID:      chatId,
+		Text:        msgText.sb.String(),
+		Entities:    format,
+		ReplyMarkup: &api.InlineKeyboardMarkup{InlineKeyboard: btnList},
+	}
+	return smsg
+}
+
+func getCabinetEditMessage(lc locale, chatId int64, msgId int, userId int64, cabinet []cabinetThought) api.EditMessageText {
+	baseMsg := getCabinetMessage(lc, chatId, userId, cabinet)
+	emsg := api.EditMessageText{
+		ChatID:      chatId,
+		MessageID:   msgId,
+		Text:        baseMsg.Text,
+		ReplyMarkup: baseMsg.ReplyMarkup,
+		Entities:    baseMsg.Entities,
+	}
+	return emsg
+}
+
+// thoughts which are not in the cabinet yet
+func getThoughtChoiceEditMessage(lc locale, chatId int64, msgId int, userId int64, slot int, cabinet []cabinetThought) api.EditMessageText {
+	var msgText myStringsBuilder
+	var btnList [][]api.InlineKeyboardButton
+	msgText.concat(lc.T("cabinet.choose", slot), "\n\n")
+	for thought := thoughtVolumetric; thought <= thoughtSelfCritique; thought++ {
+		if slices.ContainsFunc(cabinet, func(ct cabinetThought) bool { return ct.Thought == thought }) {
+			continue
+		}
+		def := thoughtDefs[thought]
+		msgText.concat(lc.thoughtName(thought), "\n", lc.thoughtProblem(thought), "\n")
+		if def.ResearchChecks > 0 {
+			msgText.concat(lc.N("cabinet.research_checks", def.ResearchChecks), "\n")
+		} else {
+			msgText.concat(lc.N("cabinet.research_days", def.ResearchDays), "\n")
+		}
+		msgText.concat(lc.T("cabinet.while_researching", formatModifiers(lc, def.Researching)), "\n",
+			lc.T("cabinet.when_internalized", formatModifiers(lc, def.Internalized)), "\n\n")
+		btnList = append(btnList, []api.InlineKeyboardButton{{
+			Text:         lc.thoughtName(thought),
+			CallbackData: makeClbk(seeCabinet, cabinetInternalize, userId, int64(slot), int64(thought)),
+		}})
+	}
+	btnList = append(btnList, []api.InlineKeyboardButton{{
+		Text:         lc.T("button.back"),
+		CallbackData: makeClbk(seeCabinet, cabinetView, userId),
+	}})
+	emsg := api.EditMessageText{
+		ChatID:      chatId,
+		MessageID:   msgId,
+		Text:        strings.TrimSpace(msgText.sb.String()),
+		ReplyMarkup: &api.InlineKeyboardMarkup{InlineKeyboard: btnList},
+	}
+	return emsg
+}
+
+// short confirmation of the attempt, shown as notification
+func getAttemptCbqAnswer(lc locale, cbqId string, voice string, finished []cabinetThought) api.AnswerCallbackQuery {
+	var lines []string
+	if voice != "" {
+		lines = append(lines, voice)
+	}
+	if text := getThoughtsFinishedText(lc, finished); text != "" {
+		lines = append(lines, text)
+	}
+	text := []rune(strings.Join(lines, "\n\n"))
+	if len(text) > maxCbqAnswerLength {
+		text = append(text[:maxCbqAnswerLength-1], '…')
+	}
+	return getCbqAnswer(cbqId, string(text))
+}
+
+func getThoughtsFinishedText(lc locale, finished []cabinetThought) string {
+	if len(finished) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(finished))
+	for _, ct := range finished {
+		names = append(names, lc.thoughtName(ct.Thought))
+	}
+	return lc.T("cabinet.finished", strings.Join(names, ", "))
+}
+
+// line of the skill in the game style, e.g. "LOGIC — ...", empty if skill has nothing to say
+func formatVoice(lc locale, skill int, line string) string {
+	if line == "" {
+		return ""
+	}
+	return lc.skillVoice(skill) + " — " + line
+}
+
+func formatModifier(value int) string {
+	if value > 0 {
+		return "+" + strconv.Itoa(value)
+	}
+	return strconv.Itoa(value)
+}
+
+func formatModifiers(lc locale, mods []skillModifier) string {
+	var parts []string
+	for _, mod := range mods {
+		parts = append(parts, lc.skill(mod.Skill)+" "+formatModifier(mod.Value))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func getErrorMessage(lc locale, chatId int64, err error) api.SendMessage {
+	var msgText myStringsBuilder
+	msgText.concat(lc.T("error.prefix"), "\n", lc.error(err))
+	smsg := api.SendMessage{
+		ChatID: chatId,
+		Text:   msgText.sb.String(),
+	}
+	return smsg
+}
+
+func getTextMessage(chatId int64, text string) api.SendMessage {
+	smsg := api.SendMessage{
+		ChatID: chatId,
+		Text:   text,
+	}
+	return smsg
+}
+
+func getStartMessage(lc locale, chatId int64) api.SendMessage {
+	smsg := api.SendMessage{
+		ChatID: chatId,
+		Text:   lc.T("start"),
+	}
+	return smsg
+}
+
+// commands listed in the chat, with their descriptions, e.g. "/top - see your checks"
+// commands of operators are listed only to them, after the others
+func getHelpMessage(lc locale, chatId int64, cmds []botCommand, private bool, admin bool) api.SendMessage {
+	var msgText myStringsBuilder
+	msgText.concat(lc.T("help.title"), "\n")
+	for _, cmd := range cmds {
+		if cmd.listedIn(private) {
+			msgText.concat("/", cmd.name, " - ", lc.T("command."+cmd.name), "\n")
+		}
+	}
+	if admin {
+		msgText.concat("\n", lc.T("help.admin_title"), "\n")
+		for _, cmd := range cmds {
+			if cmd.listed == listedForAdmins {
+				msgText.concat("/", cmd.name, " - ", lc.T("command."+cmd.name), "\n")
+			}
+		}
+	}
+	smsg := api.SendMessage{
+		ChatID: chatId,
+		Text:   msgText.sb.String(),
+	}
+	return smsg
+}
+
+// commands for the menu of telegram clients, language is empty for users of other languages
+func getMyCommands(lc locale, language string, scope string, cmds []botCommand) api.SetMyCommands {
+	private := scope == api.PrivateChatsScope
+	list := make([]api.BotCommand, 0, len(cmds))
+	for _, cmd := range cmds {
+		if cmd.listedIn(private) {
+			list = append(list, api.BotCommand{Command: cmd.name, Description: lc.T("command." + cmd.name)})
+		}
+	}
+	setCmds := api.SetMyCommands{
+		Commands:     list,
+		Scope:        &api.BotCommandScope{Type: scope},
+		LanguageCode: language,
+	}
+	return setCmds
+}
+
+func getImportHelpMessage(lc locale, chatId int64) api.SendMessage {
+	smsg := api.SendMessage{
+		ChatID: chatId,
+		Text:   lc.T("import.help"),
+	}
+	return smsg
+}
+
+// template buttons create checks, the author may delete them
+func getTemplatesMessage(lc locale, chatId int64, list []template) api.SendMessage {
+	var msgText myStringsBuilder
+	// empty keyboard removes buttons of the last deleted template
+	btnList := make([][]api.InlineKeyboardButton, 0, len(list))
+	if len(list) == 0 {
+		msgText.concat(lc.T("template.empty"))
+	} else {
+		msgText.concat(lc.T("template.title"), "\n\n")
+	}
+	for _, tmpl := range list {
+		msgText.concat(tmpl.Name, ": ", lc.typ(tmpl.Typ), " ", lc.skill(tmpl.Skill), " - ",
+			lc.difficulty(tmpl.Difficulty), "\n", tmpl.Description, "\n")
+		btnList = append(btnList, []api.InlineKeyboardButton{
+			{Text: tmpl.Name, CallbackData: makeClbk(useTemplate, templateUse, tmpl.Id)},
+			{Text: lc.T("template.delete"), CallbackData: makeClbk(useTemplate, templateDelete, tmpl.Id)},
+		})
+	}
+	smsg := api.SendMessage{
+		ChatID:      chatId,
+		Text:        msgText.sb.String(),
+		ReplyMarkup: &api.InlineKeyboardMarkup{InlineKeyboard: btnList},
+	}
+	return smsg
+}
+
+func getTemplatesEditMessage(lc locale, chatId int64, msgId int, list []template) api.EditMessageText {
+	baseMsg := getTemplatesMessage(lc, chatId, list)
+	emsg := api.EditMessageText{
+		ChatID:      chatId,
+		MessageID:   msgId,
+		Text:        baseMsg.Text,
+		ReplyMarkup: baseMsg.ReplyMarkup,
+	}
+	return emsg
+}
+
+func getImportPreviewMessage(lc locale, chatId int64, list []check) api.SendMessage {
 	var msgText myStringsBuilder
-	msgText.concat("Request was not handled due to error:\n", err.Error())
+	var attempts int
+	for _, chk := range list {
+		attempts += len(chk.Attempts)
+	}
+	msgText.concat(lc.N("import.preview", len(list), lc.N("import.attempts", attempts)), "\n\n")
+	for i, chk := range list {
+		if i == maxImportPreview {
+			msgText.concat(lc.N("import.more", len(list)-maxImportPreview), "\n")
+			break
+		}
+		msgText.concat(strconv.Itoa(i+1), ". ", lc.typ(chk.Typ), " ", lc.skill(chk.Skill), " - ",
+			lc.difficulty(chk.Difficulty), "\n", chk.Description, "\n")
+		if chk.closed() {
+			msgText.concat(lc.result(chk.Attempts[len(chk.Attempts)-1].Result), "\n")
+		}
+	}
 	smsg := api.SendMessage{
 		ChatID: chatId,
 		Text:   msgText.sb.String(),
+		ReplyMarkup: &api.InlineKeyboardMarkup{
+			InlineKeyboard: [][]api.InlineKeyboardButton{
+				{{Text: lc.T("import.confirm"), CallbackData: makeClbk(importChecks, importConfirm, 0)},
+					{Text: lc.result(resCanceled), CallbackData: makeClbk(importChecks, importCancel, 0)}},
+			},
+		},
 	}
 	return smsg
 }
 
-func getStartMessage(chatId int64) api.SendMessage {
+// list is nil when import was canceled
+func getImportResultEditMessage(lc locale, chatId int64, msgId int, list []check) api.EditMessageText {
+	emsg := api.EditMessageText{
+		ChatID:    chatId,
+		MessageID: msgId,
+		Text:      lc.T("import.canceled"),
+	}
+	if list != nil {
+		emsg.Text = lc.N("import.done", len(list))
+	}
+	return emsg
+}
+
+func getAdminStatsMessage(lc locale, chatId int64, stats botStats, checkDrafts int, importDrafts int, uptime time.Duration) api.SendMessage {
+	var msgText myStringsBuilder
+	msgText.concat(lc.T("admin.stats"), "\n",
+		lc.T("admin.users", stats.Users), "\n",
+		lc.T("admin.chats", stats.Chats), "\n",
+		lc.T("admin.checks", stats.Checks), "\n",
+		lc.T("admin.attempts", stats.Attempts), "\n",
+		lc.T("admin.drafts", checkDrafts, importDrafts), "\n",
+		lc.T("admin.uptime", uptime.Round(time.Second).String()))
 	smsg := api.SendMessage{
 		ChatID: chatId,
-		Text: `Welcome!
-You are able to create new /white, retriable checks, and /red, non-retriable checks.
-Use /top command in order to discover your checks and make an attempt to pass them.`,
+		Text:   msgText.sb.String(),
 	}
 	return smsg
 }
 
+func getBroadcastPreviewMessage(lc locale, chatId int64, text string, chats int) api.SendMessage {
+	var msgText myStringsBuilder
+	msgText.concat(lc.N("broadcast.preview", chats), "\n\n", text)
+	smsg := api.SendMessage{
+		ChatID: chatId,
+		Text:   msgText.sb.String(),
+		ReplyMarkup: &api.InlineKeyboardMarkup{
+			InlineKeyboard: [][]api.InlineKeyboardButton{
+				{{Text: lc.T("broadcast.confirm"), CallbackData: makeClbk(broadcast, broadcastConfirm, 0)},
+					{Text: lc.result(resCanceled), CallbackData: makeClbk(broadcast, broadcastCancel, 0)}},
+			},
+		},
+	}
+	return smsg
+}
+
+// nil chats mean canceled broadcast
+func getBroadcastResultEditMessage(lc locale, chatId int64, msgId int, chats []int64) api.EditMessageText {
+	emsg := api.EditMessageText{
+		ChatID:    chatId,
+		MessageID: msgId,
+		Text:      lc.T("broadcast.canceled"),
+	}
+	if chats != nil {
+		emsg.Text = lc.N("broadcast.started", len(chats))
+	}
+	return emsg
+}
+
+// card of the check with details for operators: author, chat and authors of attempts
+func getInspectMessage(lc locale, chatId int64, chk check, owner user) api.SendMessage {
+	smsg := getSingleCheckMessage(lc, chatId, chk, "")
+	var msgText myStringsBuilder
+	msgText.concat(smsg.Text, "\n",
+		lc.T("inspect.check", chk.Id), "\n",
+		lc.T("inspect.author", owner.displayName(), chk.CreatedByUser), "\n",
+		lc.T("inspect.chat", chk.CreatedByChat), "\n")
+	if chk.SeriesId != 0 {
+		msgText.concat(lc.T("inspect.series", chk.SeriesId), "\n")
+	}
+	for _, att := range chk.Attempts {
+		msgText.concat(lc.T("inspect.attempt", lc.dateTime(att.CreatedAt), lc.result(att.Result), att.CreatedByUser), "\n")
+	}
+	smsg.Text = msgText.sb.String()
+	return smsg
+}
+
+func getLeaderboardMessage(lc locale, chatId int64, period int, rows []leaderboardRow) api.SendMessage {
+	var msgText myStringsBuilder
+	var format []api.MessageEntity
+	var periodRow []api.InlineKeyboardButton
+	writeSection := func(title string, list []leaderboardRow, value func(leaderboardRow) string) {
+		boldBegin := len(utf16.Encode([]rune(msgText.sb.String())))
+		msgText.concat(title)
+		boldEnd := len(utf16.Encode([]rune(msgText.sb.String())))
+		format = append(format, api.MessageEntity{
+			Type:   api.BoldEntity,
+			Offset: boldBegin,
+			Length: boldEnd - boldBegin,
+		})
+		msgText.sb.WriteString("\n")
+		for i, row := range list {
+			if i == maxLeaderboardRows {
+				break
+			}
+			msgText.concat(strconv.Itoa(i+1), ". ", row.user().displayName(), " - ", value(row), "\n")
+		}
+		if len(list) == 0 {
+			msgText.sb.WriteString("-\n")
+		}
+		msgText.sb.WriteString("\n")
+	}
+	passed := slices.DeleteFunc(slices.Clone(rows), func(row leaderboardRow) bool {
+		return row.Successes == 0
+	})
+	rated := slices.DeleteFunc(slices.Clone(rows), func(row leaderboardRow) bool {
+		return row.WeightedAttempts == 0
+	})
+
+	msgText.concat(lc.T("leaderboard.title", lc.period(period)), "\n\n")
+	if len(rated) == 0 {
+		msgText.sb.WriteString(lc.T("leaderboard.empty"))
+	} else {
+		slices.SortStableFunc(passed, func(a, b leaderboardRow) int {
+			return b.Successes - a.Successes
+		})
+		writeSection(lc.T("leaderboard.successes"), passed, func(row leaderboardRow) string {
+			return strconv.Itoa(row.Successes)
+		})
+		slices.SortStableFunc(rated, func(a, b leaderboardRow) int {
+			return cmp.Compare(b.weightedRate(), a.weightedRate())
+		})
+		writeSection(lc.T("leaderboard.rate"), rated, func(row leaderboardRow) string {
+			return strconv.Itoa(int(row.weightedRate()*100)) + "%"
+		})
+		slices.SortStableFunc(passed, func(a, b leaderboardRow) int {
+			return b.Hardest - a.Hardest
+		})
+		writeSection(lc.T("leaderboard.hardest"), passed, func(row leaderboardRow) string {
+			return lc.difficulty(row.Hardest)
+		})
+	}
+	for i := range periodDays {
+		name := lc.period(i)
+		if i == period {
+			name = "• " + name + " •"
+		}
+		periodRow = append(periodRow, api.InlineKeyboardButton{
+			Text:         name,
+			CallbackData: makeClbk(seeLeaderboard, int64(i), 0),
+		})
+	}
+	smsg := api.SendMessage{
+		ChatID:      chatId,
+		Text:        strings.TrimSpace(msgText.sb.String()),
+		Entities:    format,
+		ReplyMarkup: &api.InlineKeyboardMarkup{InlineKeyboard: [][]api.InlineKeyboardButton{periodRow}},
+	}
+	return smsg
+}
+
+func getLeaderboardEditMessage(lc locale, chatId int64, msgId int, period int, rows []leaderboardRow) api.EditMessageText {
+	baseMsg := getLeaderboardMessage(lc, chatId, period, rows)
+	emsg := api.EditMessageText{
+		ChatID:      chatId,
+		MessageID:   msgId,
+		Text:        baseMsg.Text,
+		ReplyMarkup: baseMsg.ReplyMarkup,
+		Entities:    baseMsg.Entities,
+	}
+	return emsg
+}
+
+// languages of the catalogs, each named in itself, and automatic choice by telegram settings
+func getLanguageMessage(lc locale, chatId int64, languages []string) api.SendMessage {
+	btnList := [][]api.InlineKeyboardButton{
+		{{Text: lc.T("language.auto"), CallbackData: makeClbk(setLanguage, languageAuto, 0)}},
+	}
+	for i, lang := range languages {
+		btnList = append(btnList, []api.InlineKeyboardButton{{
+			Text:         newLocale(lang).T("language.name"),
+			CallbackData: makeClbk(setLanguage, languageSet, int64(i)),
+		}})
+	}
+	smsg := api.SendMessage{
+		ChatID:      chatId,
+		Text:        lc.T("language.choose", lc.T("language.name")),
+		ReplyMarkup: &api.InlineKeyboardMarkup{InlineKeyboard: btnList},
+	}
+	return smsg
+}
+
+func getLanguageEditMessage(lc locale, chatId int64, msgId int) api.EditMessageText {
+	emsg := api.EditMessageText{
+		ChatID:    chatId,
+		MessageID: msgId,
+		Text:      lc.T("language.changed", lc.T("language.name")),
+	}
+	return emsg
+}
+
+// location can be shared in private chats only
+func getTimezoneMessage(lc locale, chatId int64, private bool) api.SendMessage {
+	var btnList [][]api.InlineKeyboardButton
+	var btnRow []api.InlineKeyboardButton
+	for i, timezone := range timezoneCities {
+		btnRow = append(btnRow, api.InlineKeyboardButton{
+			Text:         lc.T("city." + timezone),
+			CallbackData: makeClbk(setTimezone, timezoneCity, int64(i)),
+		})
+		if (i+1)%maxCheckBtnInRow == 0 || i+1 == len(timezoneCities) {
+			btnList = append(btnList, btnRow)
+			btnRow = nil
+		}
+	}
+	now := lc.now()
+	for i, format := range dateFormats {
+		text := now.Format(dateLayouts[format].date)
+		if dateLayouts[format] == lc.layout {
+			text = "• " + text + " •"
+		}
+		btnRow = append(btnRow, api.InlineKeyboardButton{
+			Text:         text,
+			CallbackData: makeClbk(setTimezone, timezoneFormat, int64(i)),
+		})
+	}
+	btnList = append(btnList, btnRow)
+	if private {
+		btnList = append(btnList, []api.InlineKeyboardButton{{
+			Text:         lc.T("timezone.share_location"),
+			CallbackData: makeClbk(setTimezone, timezoneLocation, 0),
+		}})
+	}
+	smsg := api.SendMessage{
+		ChatID:      chatId,
+		Text:        lc.T("timezone.current", lc.zoneName(), lc.dateTime(now)),
+		ReplyMarkup: &api.InlineKeyboardMarkup{InlineKeyboard: btnList},
+	}
+	return smsg
+}
+
+func getTimezoneEditMessage(lc locale, chatId int64, msgId int, private bool) api.EditMessageText {
+	baseMsg := getTimezoneMessage(lc, chatId, private)
+	emsg := api.EditMessageText{
+		ChatID:      chatId,
+		MessageID:   msgId,
+		Text:        baseMsg.Text,
+		ReplyMarkup: baseMsg.ReplyMarkup,
+	}
+	return emsg
+}
+
+func getLocationRequestMessage(lc locale, chatId int64) api.SendReplyKeyboard {
+	smsg := api.SendReplyKeyboard{
+		ChatID: chatId,
+		Text:   lc.T("timezone.location_prompt"),
+		ReplyMarkup: &api.ReplyKeyboardMarkup{
+			Keyboard: [][]api.KeyboardButton{
+				{{Text: lc.T("timezone.share_location"), RequestLocation: true}},
+				{{Text: lc.T("button.cancel")}},
+			},
+			ResizeKeyboard:  true,
+			OneTimeKeyboard: true,
+		},
+	}
+	return smsg
+}
+
+// removes location keyboard, changed is false when user canceled the request
+func getLocationResultMessage(lc locale, chatId int64, changed bool) api.SendReplyKeyboard {
+	smsg := api.SendReplyKeyboard{
+		ChatID:      chatId,
+		Text:        lc.T("timezone.unchanged"),
+		ReplyMarkup: &api.ReplyKeyboardMarkup{RemoveKeyboard: true},
+	}
+	if changed {
+		smsg.Text = lc.T("timezone.changed", lc.zoneName(), lc.dateTime(lc.now()))
+	}
+	return smsg
+}
+
+// open checks as cards which can be posted to any chat, with start links to open and copy each one
+func getInlineQueryAnswer(lc locale, iqId string, list []check, startLink func(action int, checkId int64) string) api.AnswerInlineQuery {
+	results := make([]api.InlineQueryResultArticle, 0, len(list))
+	for _, chk := range list {
+		var msgText myStringsBuilder
+		msgText.concat(getCheckTypeName(lc, chk), ":\n")
+		boldBegin := len(utf16.Encode([]rune(msgText.sb.String()))) - 1
+		msgText.concat(lc.skill(chk.Skill), " - ", lc.difficulty(chk.Difficulty), "\n")
+		boldEnd := len(utf16.Encode([]rune(msgText.sb.String()))) - 1
+		msgText.concat(chk.Description, "\n")
+		if rule := chk.rule(); !rule.empty() {
+			msgText.concat("\n", lc.T("check.repeats", rule.describe(lc)), "\n")
+		} else if !chk.DueAt.IsZero() {
+			msgText.concat("\n", lc.T("check.due", lc.dateTime(chk.DueAt)), "\n")
+		}
+		results = append(results, api.InlineQueryResultArticle{
+			Type:        api.ArticleResult,
+			ID:          strconv.FormatInt(chk.Id, 10),
+			Title:       lc.skill(chk.Skill) + " - " + lc.difficulty(chk.Difficulty),
+			Description: chk.Description,
+			InputMessageContent: api.InputTextMessageContent{
+				MessageText: msgText.sb.String(),
+				Entities:    []api.MessageEntity{{Type: api.BoldEntity, Offset: boldBegin, Length: boldEnd - boldBegin}},
+			},
+			ReplyMarkup: &api.InlineKeyboardMarkup{
+				InlineKeyboard: [][]api.InlineKeyboardButton{
+					{{Text: lc.T("link.open"), URL: startLink(linkOpen, chk.Id)},
+						{Text: lc.T("link.clone"), URL: startLink(linkClone, chk.Id)}},
+				},
+			},
+		})
+	}
+	answer := api.AnswerInlineQuery{
+		InlineQueryID: iqId,
+		Results:       results,
+		CacheTime:     inlineCacheTime,
+		IsPersonal:    true,
+		Button:        &api.InlineQueryResultsButton{Text: lc.T("inline.create"), StartParameter: inlineStartPayload},
+	}
+	return answer
+}
+
 func getCbqAnswer(cbqId string, text string) api.AnswerCallbackQuery {
 	answer := api.AnswerCallbackQuery{
 		CallbackQueryId: cbqId,
@@ -255,9 +1032,18 @@ func getCbqAnswer(cbqId string, text string) api.AnswerCallbackQuery {
 	return answer
 }
 
-func getErrorCbqAnswer(cbqId string, err error) api.AnswerCallbackQuery {
+func getAlertCbqAnswer(cbqId string, text string) api.AnswerCallbackQuery {
+	answer := api.AnswerCallbackQuery{
+		CallbackQueryId: cbqId,
+		Text:            text,
+		ShowAlert:       true,
+	}
+	return answer
+}
+
+func getErrorCbqAnswer(lc locale, cbqId string, err error) api.AnswerCallbackQuery {
 	var msgText myStringsBuilder
-	msgText.concat("Request was not handled due to error:\n", err.Error())
+	msgText.concat(lc.T("error.prefix"), "\n", lc.error(err))
 	answer := api.AnswerCallbackQuery{
 		CallbackQueryId: cbqId,
 		Text:            msgText.sb.String(),
@@ -266,6 +1052,26 @@ func getErrorCbqAnswer(cbqId string, err error) api.AnswerCallbackQuery {
 	return answer
 }
 
+// due date lines, recurring checks show the rule and the streak too
+func writeCheckSchedule(lc locale, msgText *myStringsBuilder, chk check) {
+	if rule := chk.rule(); !rule.empty() {
+		msgText.concat(lc.T("check.repeats", rule.describe(lc)), "\n", lc.T("check.streak", chk.Streak))
+		if chk.Streak > 0 {
+			msgText.sb.WriteString(" 🔥")
+		}
+		msgText.concat("\n", lc.T("check.next_due", lc.dateTime(chk.nextDue(lc.now()))), "\n")
+	} else if !chk.DueAt.IsZero() {
+		msgText.concat(lc.T("check.due", lc.dateTime(chk.DueAt)), "\n")
+	}
+}
+
+func getCheckTypeName(lc locale, chk check) string {
+	if chk.Party {
+		return lc.typ(chk.Typ) + " 👥"
+	}
+	return lc.typ(chk.Typ)
+}
+
 func makeClbk(start string, params ...int64) string {
 	var sb strings.Builder
 	for i := 0; i < len(params); i++ {
@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// content type of prometheus text format
+const ContentType string = "text/plain; version=0.0.4; charset=utf-8"
+
+// buckets of latencies in seconds, from a millisecond to a minute
+var LatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// metrics of the bot, created once at start by packages which update them
+var Default *Registry = NewRegistry()
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// metrics written together in prometheus text format, in order of creation
+type Registry struct {
+	mutex      sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (this *Registry) register(c collector) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.collectors = append(this.collectors, c)
+}
+
+// ServeHTTP writes all metrics, meant to be served at /metrics
+func (this *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	this.mutex.Lock()
+	collectors := slices.Clone(this.collectors)
+	this.mutex.Unlock()
+	buf := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(buf)
+	}
+	buf.Flush()
+}
+
+// values of metric with labels, keyed by joined label values
+type family[V any] struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+	mutex  sync.Mutex
+	values map[string]*V
+	order  []string
+}
+
+// value of the labels, created on first use, label values must be given in order of label names
+func (this *family[V]) with(newValue func() *V, labelValues []string) (*V, func()) {
+	if len(labelValues) != len(this.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, %d values given", this.name, len(this.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	this.mutex.Lock()
+	value, ok := this.values[key]
+	if !ok {
+		value = newValue()
+		this.values[key] = value
+		this.order = append(this.order, key)
+	}
+	return value, this.mutex.Unlock
+}
+
+func (this *family[V]) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", this.name, escapeHelp(this.help), this.name, this.typ)
+}
+
+// e.g. `{method="getMe",status="200"}`, extra pair is appended, e.g. le of histogram bucket
+func (this *family[V]) formatLabels(key string, extra ...string) string {
+	var pairs []string
+	if len(this.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, this.labels[i]+"="+strconv.Quote(value))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"="+strconv.Quote(extra[i+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// counts events, e.g. updates by type
+type CounterVec struct {
+	family[float64]
+}
+
+func (this *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	counter := &CounterVec{family[float64]{name, help, "counter", labels, sync.Mutex{}, make(map[string]*float64), nil}}
+	this.register(counter)
+	return counter
+}
+
+func (this *CounterVec) Inc(labelValues ...string) {
+	this.Add(1, labelValues...)
+}
+
+func (this *CounterVec) Add(delta float64, labelValues ...string) {
+	value, unlock := this.with(func() *float64 { return new(float64) }, labelValues)
+	defer unlock()
+	*value += delta
+}
+
+func (this *CounterVec) write(w *bufio.Writer) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.writeHeader(w)
+	for _, key := range this.order {
+		fmt.Fprintf(w, "%s%s %s\n", this.name, this.formatLabels(key), formatFloat(*this.values[key]))
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// counts observations in cumulative buckets, e.g. latency of handlers
+type HistogramVec struct {
+	family[histogram]
+	buckets []float64
+}
+
+// buckets are upper bounds in ascending order, +Inf is added
+func (this *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	hist := &HistogramVec{
+		family[histogram]{name, help, "histogram", labels, sync.Mutex{}, make(map[string]*histogram), nil},
+		buckets,
+	}
+	this.register(hist)
+	return hist
+}
+
+func (this *HistogramVec) Observe(value float64, labelValues ...string) {
+	hist, unlock := this.with(func() *histogram {
+		return &histogram{counts: make([]uint64, len(this.buckets))}
+	}, labelValues)
+	defer unlock()
+	for i, bound := range this.buckets {
+		if value <= bound {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += value
+}
+
+// observes seconds since begin, e.g. defer hist.Since(time.Now(), "getMe")
+func (this *HistogramVec) Since(begin time.Time, labelValues ...string) {
+	this.Observe(time.Since(begin).Seconds(), labelValues...)
+}
+
+func (this *HistogramVec) write(w *bufio.Writer) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.writeHeader(w)
+	for _, key := range this.order {
+		hist := this.values[key]
+		for i, bound := range this.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", this.name, this.formatLabels(key, "le", formatFloat(bound)), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", this.name, this.formatLabels(key, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", this.name, this.formatLabels(key), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", this.name, this.formatLabels(key), hist.count)
+	}
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recurrence kinds
+const (
+	recDaily = iota + 1
+	recWeekdays
+	recWeekly
+	recEveryNDays
+)
+
+const maxRecurrenceDays int = 365
+
+// rule of repeating check, stored as text, e.g. "daily", "weekly:1,3" or "every:3"
+type recurrence struct {
+	kind  int
+	days  []time.Weekday // for recWeekly
+	every int            // for recEveryNDays
+}
+
+// parses command arguments like "daily", "weekdays", "weekly mon thu" or "every 3",
+// arguments which are not part of the rule are returned back
+func parseRecurrenceArgs(args []string) (recurrence, []string, error) {
+	var rule recurrence
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToLower(args[i])
+		switch {
+		case rule.kind != 0:
+			if weekday, ok := dueWeekdays[arg]; ok && rule.kind == recWeekly {
+				if !slices.Contains(rule.days, weekday) {
+					rule.days = append(rule.days, weekday)
+				}
+				continue
+			}
+			rest = append(rest, args[i])
+		case arg == "daily":
+			rule.kind = recDaily
+		case arg == "weekdays":
+			rule.kind = recWeekdays
+		case arg == "weekly":
+			rule.kind = recWeekly
+		case arg == "every":
+			rule.kind = recEveryNDays
+			if i+1 == len(args) {
+				return recurrence{}, nil, newUserError("error.every_days_missing")
+			}
+			i++
+			every, err := strconv.Atoi(args[i])
+			if err != nil || every < 1 || every > maxRecurrenceDays {
+				return recurrence{}, nil, newUserError("error.every_days_invalid", args[i], maxRecurrenceDays)
+			}
+			rule.every = every
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	if rule.kind == recWeekly && len(rule.days) == 0 {
+		return recurrence{}, nil, newUserError("error.weekly_days_missing")
+	}
+	slices.Sort(rule.days)
+	return rule, rest, nil
+}
+
+func parseRecurrence(value string) (recurrence, error) {
+	var rule recurrence
+	kind, param, _ := strings.Cut(value, ":")
+	switch kind {
+	case "":
+		return recurrence{}, nil
+	case "daily":
+		rule.kind = recDaily
+	case "weekdays":
+		rule.kind = recWeekdays
+	case "weekly":
+		rule.kind = recWeekly
+		for _, day := range strings.Split(param, ",") {
+			weekday, err := strconv.Atoi(day)
+			if err != nil || weekday < int(time.Sunday) || weekday > int(time.Saturday) {
+				return recurrence{}, fmt.Errorf("invalid recurrence %q", value)
+			}
+			rule.days = append(rule.days, time.Weekday(weekday))
+		}
+	case "every":
+		every, err := strconv.Atoi(param)
+		if err != nil || every < 1 || every > maxRecurrenceDays {
+			return recurrence{}, fmt.Errorf("invalid recurrence %q", value)
+		}
+		rule.kind = recEveryNDays
+		rule.every = every
+	default:
+		return recurrence{}, fmt.Errorf("invalid recurrence %q", value)
+	}
+	return rule, nil
+}
+
+func (this recurrence) empty() bool {
+	return this.kind == 0
+}
+
+func (this recurrence) String() string {
+	switch this.kind {
+	case recDaily:
+		return "daily"
+	case recWeekdays:
+		return "weekdays"
+	case recWeekly:
+		days := make([]string, 0, len(this.days))
+		for _, day := range this.days {
+			days = append(days, strconv.Itoa(int(day)))
+		}
+		return "weekly:" + strings.Join(days, ",")
+	case recEveryNDays:
+		return "every:" + strconv.Itoa(this.every)
+	}
+	return ""
+}
+
+// human readable rule, e.g. "weekly on Mon, Thu"
+func (this recurrence) describe(lc locale) string {
+	switch this.kind {
+	case recDaily:
+		return lc.T("recurrence.daily")
+	case recWeekdays:
+		return lc.T("recurrence.weekdays")
+	case recWeekly:
+		days := make([]string, 0, len(this.days))
+		for _, day := range this.days {
+			days = append(days, lc.weekday(day))
+		}
+		return lc.T("recurrence.weekly", strings.Join(days, ", "))
+	case recEveryNDays:
+		return lc.N("recurrence.every", this.every)
+	}
+	return ""
+}
+
+func (this recurrence) matches(day time.Time) bool {
+	switch this.kind {
+	case recWeekdays:
+		return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+	case recWeekly:
+		return slices.Contains(this.days, day.Weekday())
+	}
+	return true
+}
+
+// first occurrence after now, at the time of the day of prev,
+// for every N days occurrences are counted from prev
+func (this recurrence) next(prev time.Time, now time.Time) time.Time {
+	step := 1
+	if this.kind == recEveryNDays {
+		step = this.every
+	}
+	occurrence := prev.AddDate(0, 0, step)
+	for !occurrence.After(now) || !this.matches(occurrence) {
+		occurrence = occurrence.AddDate(0, 0, step)
+	}
+	return occurrence
+}
+
+// first occurrence of the new rule, at default hour
+func (this recurrence) first(now time.Time) time.Time {
+	year, month, day := now.Date()
+	occurrence := time.Date(year, month, day, defaultDueHour, 0, 0, 0, now.Location())
+	if occurrence.After(now) && this.matches(occurrence) {
+		return occurrence
+	}
+	if this.kind == recEveryNDays {
+		// starting from today, not in N days
+		return occurrence.AddDate(0, 0, 1)
+	}
+	return this.next(occurrence, now)
+}
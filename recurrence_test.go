@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseRecurrenceArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		want     string
+		wantRest []string
+		wantErr  string
+	}{
+		{name: "no rule", args: []string{"party"}, want: "", wantRest: []string{"party"}},
+		{name: "daily", args: []string{"Daily"}, want: "daily"},
+		{name: "weekdays with flag", args: []string{"weekdays", "party"}, want: "weekdays", wantRest: []string{"party"}},
+		{name: "weekly days sorted once", args: []string{"weekly", "thu", "Mon", "thursday"}, want: "weekly:1,4"},
+		{name: "weekly russian days", args: []string{"weekly", "пн", "пятницу"}, want: "weekly:1,5"},
+		{name: "every n days", args: []string{"every", "3"}, want: "every:3"},
+		{name: "weekly without days", args: []string{"weekly"}, wantErr: "error.weekly_days_missing"},
+		{name: "every without days", args: []string{"every"}, wantErr: "error.every_days_missing"},
+		{name: "every zero days", args: []string{"every", "0"}, wantErr: "error.every_days_invalid"},
+		{name: "every too many days", args: []string{"every", "366"}, wantErr: "error.every_days_invalid"},
+		{name: "every not a number", args: []string{"every", "few"}, wantErr: "error.every_days_invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, rest, err := parseRecurrenceArgs(tt.args)
+			if tt.wantErr != "" {
+				var uerr userError
+				if !errors.As(err, &uerr) || uerr.key != tt.wantErr {
+					t.Fatalf("error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rule.String() != tt.want || !slices.Equal(rest, tt.wantRest) {
+				t.Errorf("got %q and %v, want %q and %v", rule.String(), rest, tt.want, tt.wantRest)
+			}
+			if parsed, err := parseRecurrence(rule.String()); err != nil || parsed.String() != tt.want {
+				t.Errorf("stored rule %q is parsed as %q, %v", rule.String(), parsed.String(), err)
+			}
+		})
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(year int, month time.Month, day int, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, newYork)
+	}
+	tests := []struct {
+		name string
+		rule string
+		prev time.Time
+		now  time.Time
+		want time.Time
+	}{
+		{name: "daily next day", rule: "daily", prev: at(2024, 5, 15, 18), now: at(2024, 5, 15, 19), want: at(2024, 5, 16, 18)},
+		{name: "daily over month end", rule: "daily", prev: at(2024, 1, 31, 18), now: at(2024, 1, 31, 19), want: at(2024, 2, 1, 18)},
+		{name: "daily over leap day", rule: "daily", prev: at(2024, 2, 28, 18), now: at(2024, 2, 28, 19), want: at(2024, 2, 29, 18)},
+		{name: "every n days over month end", rule: "every:3", prev: at(2024, 4, 29, 8), now: at(2024, 4, 29, 9), want: at(2024, 5, 2, 8)},
+		{name: "every n days over year end", rule: "every:7", prev: at(2024, 12, 28, 8), now: at(2024, 12, 28, 9), want: at(2025, 1, 4, 8)},
+		{name: "weekdays skip weekend", rule: "weekdays", prev: at(2024, 5, 17, 18), now: at(2024, 5, 17, 19), want: at(2024, 5, 20, 18)},
+		{name: "weekly on days", rule: "weekly:1,4", prev: at(2024, 5, 13, 18), now: at(2024, 5, 13, 19), want: at(2024, 5, 16, 18)},
+		{name: "daily keeps hour when dst starts", rule: "daily", prev: at(2024, 3, 9, 18), now: at(2024, 3, 9, 19), want: at(2024, 3, 10, 18)},
+		{name: "daily keeps hour when dst ends", rule: "daily", prev: at(2024, 11, 2, 18), now: at(2024, 11, 2, 19), want: at(2024, 11, 3, 18)},
+		{name: "elapsed daily periods", rule: "daily", prev: at(2024, 5, 1, 18), now: at(2024, 5, 10, 12), want: at(2024, 5, 10, 18)},
+		{name: "elapsed periods keep every n days phase", rule: "every:3", prev: at(2024, 5, 1, 18), now: at(2024, 5, 10, 19), want: at(2024, 5, 13, 18)},
+		{name: "elapsed weekly periods", rule: "weekly:3", prev: at(2024, 5, 1, 18), now: at(2024, 5, 20, 12), want: at(2024, 5, 22, 18)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := parseRecurrence(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rule.next(tt.prev, tt.now); !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecurrenceFirst(t *testing.T) {
+	zone := time.FixedZone("MSK", 3*60*60)
+	tests := []struct {
+		name string
+		rule string
+		now  time.Time
+		want time.Time
+	}{
+		{name: "today before default hour", rule: "daily", now: time.Date(2024, 5, 15, 12, 0, 0, 0, zone), want: time.Date(2024, 5, 15, 18, 0, 0, 0, zone)},
+		{name: "tomorrow after default hour", rule: "daily", now: time.Date(2024, 5, 31, 19, 0, 0, 0, zone), want: time.Date(2024, 6, 1, 18, 0, 0, 0, zone)},
+		{name: "every n days starts tomorrow", rule: "every:5", now: time.Date(2024, 5, 15, 19, 0, 0, 0, zone), want: time.Date(2024, 5, 16, 18, 0, 0, 0, zone)},
+		{name: "weekly on the next day of week", rule: "weekly:1", now: time.Date(2024, 5, 15, 12, 0, 0, 0, zone), want: time.Date(2024, 5, 20, 18, 0, 0, 0, zone)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := parseRecurrence(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rule.first(tt.now); !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"discocheckbot/api"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// template being saved from a check, when it has no id yet, or being used,
+// then the next messages of the user in the chat are values of its placeholders
+type templateDraft struct {
+	tmpl   template
+	values []string
+	// the last prompt, see promptTimeout
+	askedAt time.Time
+}
+
+// in group chats templates shared in the chat are listed instead of personal ones
+func (this *DiscoCheckBot) listTemplates(chat *api.Chat, userId int64) ([]template, error) {
+	if chat.Type == api.PrivateChat {
+		return this.db.listUserTemplates(userId)
+	}
+	return this.db.listChatTemplates(chat.ID)
+}
+
+func (this *DiscoCheckBot) displayTemplates(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
+	list, err := this.listTemplates(msg.Chat, msg.Sender.ID)
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	bot.SendMessage(getTemplatesMessage(lc, msg.Chat.ID, list))
+	return nil
+}
+
+// template of a party check is shared in its chat, the name is asked next
+func (this *DiscoCheckBot) startSaveTemplate(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
+	checkId, err := strconv.ParseInt(clbkPar[2], 10, 64)
+	if err != nil {
+		return false, err
+	}
+	chk, err := this.readCheck(checkId)
+	if err == nil && chk.CreatedByUser != cbq.Sender.ID {
+		err = newUserError("error.check_other_user", chk.Id)
+	}
+	tmpl := template{
+		Skill:         chk.Skill,
+		Difficulty:    chk.Difficulty,
+		Typ:           chk.Typ,
+		Description:   chk.Description,
+		Party:         chk.Party,
+		CreatedByUser: cbq.Sender.ID,
+		CreatedByChat: cbq.Message.Chat.ID,
+	}
+	var list []template
+	if err == nil && chk.Party {
+		tmpl.CreatedByChat = chk.CreatedByChat
+		list, err = this.db.listChatTemplates(chk.CreatedByChat)
+	} else if err == nil {
+		list, err = this.db.listUserTemplates(cbq.Sender.ID)
+	}
+	if err == nil && len(list) >= maxTemplates {
+		err = newUserError("error.too_many_templates", maxTemplates)
+	}
+	if err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	this.templateBuffer[dialogOfCallback(cbq)] = templateDraft{tmpl: tmpl, askedAt: time.Now()}
+	bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+	bot.SendMessage(getTextMessage(cbq.Message.Chat.ID, lc.T("template.name_prompt")))
+	return true, nil
+}
+
+func (this *DiscoCheckBot) handleTemplateText(bot *api.Bot, msg *api.Message) error {
+	if this.templateBuffer[dialogOf(msg)].tmpl.Id == 0 {
+		return this.saveTemplate(bot, msg)
+	}
+	return this.fillTemplate(bot, msg)
+}
+
+// the first line is the name, the next ones replace description of the check, e.g. to add placeholders
+func (this *DiscoCheckBot) saveTemplate(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
+	tmpl := this.templateBuffer[dialogOf(msg)].tmpl
+	delete(this.templateBuffer, dialogOf(msg))
+	name, descr, _ := strings.Cut(msg.Text, "\n")
+	tmpl.Name = strings.TrimSpace(name)
+	if descr = strings.TrimSpace(descr); descr != "" {
+		tmpl.Description = descr
+	}
+	err := tmpl.validate()
+	if err == nil {
+		err = this.db.createTemplate(&tmpl)
+	}
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	bot.SendMessage(getTextMessage(msg.Chat.ID, lc.T("template.saved", tmpl.Name)))
+	return nil
+}
+
+// values are asked one by one, the check is created after the last one
+func (this *DiscoCheckBot) fillTemplate(bot *api.Bot, msg *api.Message) error {
+	lc := this.locale(msg.Sender)
+	draft := this.templateBuffer[dialogOf(msg)]
+	delete(this.templateBuffer, dialogOf(msg))
+	names := draft.tmpl.placeholders()
+	value := strings.TrimSpace(msg.Text)
+	if value == "" {
+		err := newUserError("error.template_value_empty", names[len(draft.values)])
+		bot.SendMessage(getErrorMessage(lc, msg.Chat.ID, err))
+		return err
+	}
+	draft.values = append(draft.values, value)
+	if len(draft.values) < len(names) {
+		draft.askedAt = time.Now()
+		this.templateBuffer[dialogOf(msg)] = draft
+		bot.SendMessage(getTextMessage(msg.Chat.ID, lc.T("template.value_prompt", names[len(draft.values)])))
+		return nil
+	}
+	return this.createFromTemplate(bot, lc, draft.tmpl, draft.values, msg.Sender.ID, msg.Chat.ID, msg.MessageID)
+}
+
+// the check goes the same way as one created step by step
+func (this *DiscoCheckBot) createFromTemplate(bot *api.Bot, lc locale, tmpl template, values []string,
+	userId int64, chatId int64, msgId int) error {
+	chk := tmpl.check(values)
+	chk.CreatedByUser = userId
+	chk.CreatedByChat = chatId
+	chk.CreatedByMessage = msgId
+	err := tmpl.usableBy(userId, chatId)
+	if err == nil && utf8.RuneCountInString(chk.Description) > maxDescriptionLength {
+		err = newUserError("error.description_too_long", maxDescriptionLength)
+	}
+	if err != nil {
+		bot.SendMessage(getErrorMessage(lc, chatId, err))
+		return err
+	}
+	return this.createNewCheck(bot, lc, chk)
+}
+
+func (this *DiscoCheckBot) handleTemplateAction(bot *api.Bot, cbq *api.CallbackQuery, clbkPar []string) (bool, error) {
+	lc := this.locale(cbq.Sender)
+	oper, err := strconv.Atoi(clbkPar[1])
+	if err != nil {
+		return false, err
+	}
+	templateId, err := strconv.ParseInt(clbkPar[2], 10, 64)
+	if err != nil {
+		return false, err
+	}
+	tmpl, err := this.db.readTemplate(templateId)
+	if err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	switch oper {
+	case templateUse:
+		if err = tmpl.usableBy(cbq.Sender.ID, cbq.Message.Chat.ID); err != nil {
+			bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+			return true, err
+		}
+		bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, ""))
+		if names := tmpl.placeholders(); len(names) > 0 {
+			this.templateBuffer[dialogOfCallback(cbq)] = templateDraft{tmpl: tmpl, askedAt: time.Now()}
+			bot.SendMessage(getTextMessage(cbq.Message.Chat.ID, lc.T("template.value_prompt", names[0])))
+			return true, nil
+		}
+		return true, this.createFromTemplate(bot, lc, tmpl, nil, cbq.Sender.ID, cbq.Message.Chat.ID, cbq.Message.MessageID)
+	case templateDelete:
+		if tmpl.CreatedByUser != cbq.Sender.ID {
+			err = newUserError("error.template_delete_other_user", tmpl.Name)
+		} else {
+			err = this.db.deleteTemplate(tmpl.Id, cbq.Sender.ID)
+		}
+	default:
+		return false, fmt.Errorf("unsupported template operation %d", oper)
+	}
+	var list []template
+	if err == nil {
+		list, err = this.listTemplates(cbq.Message.Chat, cbq.Sender.ID)
+	}
+	if err != nil {
+		bot.AnswerCallbackQuery(getErrorCbqAnswer(lc, cbq.ID, err))
+		return true, err
+	}
+	bot.AnswerCallbackQuery(getCbqAnswer(cbq.ID, lc.T("template.deleted", tmpl.Name)))
+	bot.EditMessageText(getTemplatesEditMessage(lc, cbq.Message.Chat.ID, cbq.Message.MessageID, list))
+	return true, nil
+}
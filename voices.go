@@ -0,0 +1,133 @@
+package main
+
+import (
+	"discocheckbot/i18n"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"path"
+	"strings"
+	"sync"
+)
+
+// voice events
+const (
+	voiceCreated   string = "created"
+	voiceSuccess   string = "success"
+	voiceFailure   string = "failure"
+	voiceCritical  string = "critical"
+	voiceAbandoned string = "abandoned"
+)
+
+// flavour lines of skills per language, e.g. voices/ru.json, keyed by skill name,
+// so it can be edited without programming
+//
+//go:embed voices/*.json
+var voicesData embed.FS
+
+type skillVoice struct {
+	Description string   `json:"description"`
+	Created     []string `json:"created"`
+	Success     []string `json:"success"`
+	Failure     []string `json:"failure"`
+	Critical    []string `json:"critical"`
+	Abandoned   []string `json:"abandoned"`
+}
+
+func (this skillVoice) lines(event string) []string {
+	switch event {
+	case voiceCreated:
+		return this.Created
+	case voiceSuccess:
+		return this.Success
+	case voiceFailure:
+		return this.Failure
+	case voiceCritical:
+		return this.Critical
+	case voiceAbandoned:
+		return this.Abandoned
+	}
+	return nil
+}
+
+type voiceBook struct {
+	voices map[string]*[len(skillNames)]skillVoice
+	mu     sync.Mutex
+	rnd    *rand.Rand
+}
+
+// the same seed gives the same sequence of lines, voices of the fallback language are required
+func newVoiceBook(fsys fs.FS, dir string, seed int64) (*voiceBook, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	book := voiceBook{
+		voices: make(map[string]*[len(skillNames)]skillVoice),
+		rnd:    rand.New(rand.NewSource(seed)),
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var byName map[string]skillVoice
+		if err = json.Unmarshal(data, &byName); err != nil {
+			return nil, fmt.Errorf("voices %s are broken: %w", file, err)
+		}
+		var voices [len(skillNames)]skillVoice
+		for name, voice := range byName {
+			skill, ok := lookupName(name, "skill.", skillNames[:])
+			if !ok || skill < intLogic || skill > motComposure {
+				return nil, fmt.Errorf("voices %s are broken: unknown skill %q", file, name)
+			}
+			voices[skill] = voice
+		}
+		book.voices[strings.TrimSuffix(path.Base(file), ".json")] = &voices
+	}
+	if _, ok := book.voices[i18n.Fallback]; !ok {
+		return nil, fmt.Errorf("voices of fallback language %s are missing", i18n.Fallback)
+	}
+	return &book, nil
+}
+
+// voices of the language, skills which are not translated speak the fallback language
+func (this *voiceBook) skill(lang string, skill int) skillVoice {
+	if voices, ok := this.voices[lang]; ok && voices[skill].Description != "" {
+		return voices[skill]
+	}
+	return this.voices[i18n.Fallback][skill]
+}
+
+func (this *voiceBook) description(lang string, skill int) string {
+	return this.skill(lang, skill).Description
+}
+
+// random line of the skill, empty if skill has nothing to say
+func (this *voiceBook) speak(lang string, skill int, event string) string {
+	lines := this.skill(lang, skill).lines(event)
+	if len(lines) == 0 {
+		return ""
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return lines[this.rnd.Intn(len(lines))]
+}
+
+// event of the attempt, passing very hard checks is critical
+func attemptVoiceEvent(chk check, result int) string {
+	switch result {
+	case resSuccess:
+		if chk.Difficulty >= difLegendary {
+			return voiceCritical
+		}
+		return voiceSuccess
+	case resFailure:
+		return voiceFailure
+	case resCanceled:
+		return voiceAbandoned
+	}
+	return ""
+}
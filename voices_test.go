@@ -0,0 +1,89 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestVoiceBookSeed(t *testing.T) {
+	first, err := newVoiceBook(voicesData, "voices", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newVoiceBook(voicesData, "voices", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, lang := range []string{"en", "ru"} {
+		for skill := intLogic; skill <= motComposure; skill++ {
+			if first.description(lang, skill) == "" {
+				t.Errorf("skill %d has no description in %s", skill, lang)
+			}
+			for _, event := range []string{voiceCreated, voiceSuccess, voiceFailure, voiceCritical, voiceAbandoned} {
+				got := first.speak(lang, skill, event)
+				if want := second.speak(lang, skill, event); got != want {
+					t.Fatalf("the same seed gives %q and %q for skill %d, %s in %s", got, want, skill, event, lang)
+				}
+				if lines := first.skill(lang, skill).lines(event); len(lines) > 0 && !slices.Contains(lines, got) {
+					t.Errorf("%q is not a line of skill %d, %s in %s", got, skill, event, lang)
+				}
+			}
+		}
+	}
+}
+
+func TestVoiceBookFallback(t *testing.T) {
+	fsys := fstest.MapFS{
+		"voices/en.json": {Data: []byte(`{
+			"Logic": {"description": "logic", "success": ["elementary"], "failure": ["fallacy"]},
+			"🟪 Volition": {"description": "volition", "success": ["hold on"]}
+		}`)},
+		"voices/ru.json": {Data: []byte(`{
+			"Logic": {"description": "логика", "success": ["элементарно"]}
+		}`)},
+	}
+	book, err := newVoiceBook(fsys, "voices", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		lang  string
+		skill int
+		event string
+		want  string
+	}{
+		{name: "translated", lang: "ru", skill: intLogic, event: voiceSuccess, want: "элементарно"},
+		{name: "event missing in translated skill", lang: "ru", skill: intLogic, event: voiceFailure, want: ""},
+		{name: "skill missing in language", lang: "ru", skill: psyVolition, event: voiceSuccess, want: "hold on"},
+		{name: "unknown language", lang: "de", skill: intLogic, event: voiceFailure, want: "fallacy"},
+		{name: "skill missing everywhere", lang: "en", skill: motComposure, event: voiceSuccess, want: ""},
+		{name: "unknown event", lang: "en", skill: intLogic, event: "dancing", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := book.speak(tt.lang, tt.skill, tt.event); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVoiceBookBroken(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{name: "fallback language missing", fsys: fstest.MapFS{"voices/ru.json": {Data: []byte(`{}`)}}},
+		{name: "unknown skill", fsys: fstest.MapFS{"voices/en.json": {Data: []byte(`{"Juggling": {}}`)}}},
+		{name: "broken json", fsys: fstest.MapFS{"voices/en.json": {Data: []byte(`{"Logic": `)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newVoiceBook(tt.fsys, "voices", 1); err == nil {
+				t.Error("broken voices are loaded")
+			}
+		})
+	}
+}